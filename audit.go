@@ -29,11 +29,15 @@
 package pbaudit
 
 import (
+	"crypto/tls"
 	"fmt"
 	"time"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/hook"
+	"github.com/pocketbase/pocketbase/tools/router"
 	"github.com/skeeeon/pb-audit/internal/audit"
 )
 
@@ -43,6 +47,10 @@ import (
 // When both are set, both constraints are enforced (records must satisfy both).
 // If neither is set, no cleanup is performed.
 //
+// Rules override MaxAge for specific collections and event types. The max age
+// of each record is resolved when it is written and stored in its expires_at
+// field, which the cleanup job purges by.
+//
 // Example:
 //
 //	options.Retention = &pbaudit.RetentionPolicy{
@@ -51,9 +59,357 @@ import (
 //	    Interval:   "0 2 * * *",         // Run cleanup at 2 AM daily
 //	}
 type RetentionPolicy struct {
-	MaxAge     time.Duration // Delete records older than this duration (0 = disabled)
-	MaxRecords int           // Keep at most this many records, oldest deleted first (0 = disabled)
-	Interval   string        // Cron expression for cleanup schedule (default: "0 0 * * *" = daily midnight)
+	MaxAge     time.Duration   // Delete records older than this duration (0 = disabled)
+	MaxRecords int             // Keep at most this many records, oldest deleted first (0 = disabled)
+	Rules      []RetentionRule // Max ages for specific collections/event types, first match wins
+	Interval   string          // Cron expression for cleanup schedule (default: "0 0 * * *" = daily midnight)
+	Archive    *ArchivePolicy  // Archive records before they are deleted (nil = no archive)
+}
+
+// RetentionRule sets the max age of the audit records of some collections
+// and event types, overriding RetentionPolicy.MaxAge.
+//
+// Rules are checked in order and the first matching rule wins, so list
+// specific rules before broad ones. Records matching no rule use MaxAge.
+//
+// Rules can't be combined with HashChain: they delete records from the middle
+// of the log, which breaks the chain. MaxAge and MaxRecords work with the
+// chain: retention then deletes in insertion order, up to the newest expired
+// record, since timestamps are not strictly ordered like the chain (e.g.
+// after a spill replay).
+//
+// Example:
+//
+//	options.Retention = &pbaudit.RetentionPolicy{
+//	    MaxAge: 90 * 24 * time.Hour,
+//	    Rules: []pbaudit.RetentionRule{
+//	        // Keep auth and payment events for seven years
+//	        {EventTypes: []string{"auth"}, MaxAge: 7 * 365 * 24 * time.Hour},
+//	        {Collections: []string{"payments"}, MaxAge: 7 * 365 * 24 * time.Hour},
+//	        // Drop session request noise after a week
+//	        {Collections: []string{"sessions"}, EventTypes: []string{"create_request"}, MaxAge: 7 * 24 * time.Hour},
+//	    },
+//	}
+type RetentionRule struct {
+	Collections []string      // Collections the rule applies to (empty = all collections)
+	EventTypes  []string      // Event types the rule applies to (empty = all event types)
+	MaxAge      time.Duration // Keep matching records this long (0 = keep forever)
+}
+
+// ArchivePolicy keeps a cold copy of audit records deleted by the retention job.
+//
+// Each batch is written to a gzip-compressed JSONL file with a manifest
+// (record count, SHA-256 checksum, time range) before it is deleted. Records
+// are only deleted after both files were written successfully.
+//
+// Example:
+//
+//	options.Retention = &pbaudit.RetentionPolicy{
+//	    MaxAge: 90 * 24 * time.Hour,
+//	    Archive: &pbaudit.ArchivePolicy{
+//	        Prefix:     "audit_archive",
+//	        Filesystem: pbaudit.ArchiveToAppStorage, // S3 if enabled in settings
+//	    },
+//	}
+type ArchivePolicy struct {
+	// Key prefix (directory) of the archive files (default: "audit_archive")
+	Prefix string
+
+	// Filesystem opens the archive storage (default: local, under pb_data).
+	// Use ArchiveToAppStorage, ArchiveToBackupsStorage or any function
+	// returning a PocketBase filesystem.
+	Filesystem func(app core.App) (*filesystem.System, error)
+}
+
+// ArchiveToAppStorage stores archives in the app's file storage
+// (S3 when enabled in the PocketBase settings, otherwise pb_data/storage).
+func ArchiveToAppStorage(app core.App) (*filesystem.System, error) {
+	return app.NewFilesystem()
+}
+
+// ArchiveToBackupsStorage stores archives in the app's backups storage
+// (S3 when enabled for backups, otherwise pb_data/backups).
+func ArchiveToBackupsStorage(app core.App) (*filesystem.System, error) {
+	return app.NewBackupsFilesystem()
+}
+
+// OverflowPolicy decides what happens to an event when the async queue is full.
+type OverflowPolicy string
+
+const (
+	// OverflowBlock makes the request wait until there is room in the queue (default).
+	OverflowBlock OverflowPolicy = "block"
+
+	// OverflowDrop discards the event and logs a warning.
+	OverflowDrop OverflowPolicy = "drop"
+
+	// OverflowSpill appends the event to a file on disk. Spilled events are
+	// written to the collection once the queue has room again, or on next start.
+	OverflowSpill OverflowPolicy = "spill"
+)
+
+// AsyncOptions enables asynchronous, batched writing of audit records.
+//
+// Instead of saving each audit record inside the request, events are put on a
+// bounded in-memory queue and a background worker inserts them in batched
+// transactions. The queue is flushed when the app terminates.
+//
+// Example:
+//
+//	options.Async = &pbaudit.AsyncOptions{
+//	    QueueSize:     10000,                  // Buffer up to 10k events
+//	    BatchSize:     200,                    // Insert up to 200 records per transaction
+//	    FlushInterval: 500 * time.Millisecond, // Write at least every 500ms
+//	    Overflow:      pbaudit.OverflowSpill,  // Spill to disk when the queue is full
+//	}
+type AsyncOptions struct {
+	QueueSize     int            // Maximum number of events buffered in memory (default: 10000)
+	BatchSize     int            // Maximum number of records inserted per transaction (default: 100)
+	FlushInterval time.Duration  // Maximum time an event waits before being written (default: 1s)
+	Overflow      OverflowPolicy // What to do when the queue is full (default: OverflowBlock)
+	SpillPath     string         // Spill file for OverflowSpill (default: pb_data/audit_spill.jsonl)
+}
+
+// RedactAction defines how a sensitive field is redacted in snapshots.
+type RedactAction string
+
+const (
+	// RedactDrop removes the field from the snapshot.
+	RedactDrop RedactAction = "drop"
+
+	// RedactMask replaces the value with "********".
+	RedactMask RedactAction = "mask"
+
+	// RedactHash replaces the value with "sha256:<hex>" of RedactionSalt + value.
+	// Equal values produce equal hashes, so changes remain detectable.
+	RedactHash RedactAction = "hash"
+
+	// RedactLastN keeps only the last KeepLast characters, e.g. "********4242".
+	RedactLastN RedactAction = "last_n"
+)
+
+// RedactionRule configures redaction of sensitive fields in before/after snapshots.
+//
+// Field patterns are matched case-insensitively against field names using
+// path.Match syntax ("*" matches any characters). They also match keys nested
+// inside JSON fields. Rules scoped to collections are checked before global
+// rules, and within each group the first matching rule wins.
+//
+// Example:
+//
+//	options.Redaction = append(options.Redaction,
+//	    pbaudit.RedactionRule{
+//	        Collections: []string{"payments"},
+//	        Fields:      []string{"card_number"},
+//	        Action:      pbaudit.RedactLastN,
+//	        KeepLast:    4,
+//	    },
+//	    pbaudit.RedactionRule{
+//	        Collections: []string{"customers"},
+//	        Fields:      []string{"ssn", "tax_id"},
+//	        Action:      pbaudit.RedactHash,
+//	    },
+//	)
+type RedactionRule struct {
+	Collections []string     // Collections the rule applies to (empty = all collections)
+	Fields      []string     // Field name patterns, e.g. "api_key" or "*token*"
+	Action      RedactAction // How matching fields are redacted
+	KeepLast    int          // Characters kept by RedactLastN (default: 4)
+}
+
+// DefaultRedactionRules returns the global redaction rules used by DefaultOptions.
+//
+// Fields whose names contain password, token, secret, api key or private key
+// are masked in every collection.
+func DefaultRedactionRules() []RedactionRule {
+	return []RedactionRule{
+		{
+			Fields: []string{
+				"*password*",
+				"*token*",
+				"*secret*",
+				"*apikey*",
+				"*api_key*",
+				"*privatekey*",
+				"*private_key*",
+			},
+			Action: RedactMask,
+		},
+	}
+}
+
+// Event is a single audit event as delivered to sinks.
+//
+// It carries the same data as an audit log record: event type, collection,
+// record ID, user, request metadata, timestamp, the redacted before/after
+// snapshots and the field-level changes. Its JSON form uses the audit log
+// field names.
+type Event = audit.Event
+
+// FieldChange describes how a single field changed in an update event.
+type FieldChange = audit.FieldChange
+
+// Sink is a destination for audit events, such as a file, an HTTP collector
+// or a SIEM.
+//
+// Write receives events in the order they were logged: one at a time in
+// synchronous mode, in batches when Async is enabled. Errors are reported
+// through Options.OnSinkError and never affect other sinks or the request.
+// Sinks that implement io.Closer are closed when the app terminates.
+//
+// Example:
+//
+//	type stdoutSink struct{}
+//
+//	func (stdoutSink) Name() string { return "stdout" }
+//
+//	func (stdoutSink) Write(events []pbaudit.Event) error {
+//	    for _, event := range events {
+//	        fmt.Println(event.EventType, event.CollectionName, event.RecordID)
+//	    }
+//	    return nil
+//	}
+type Sink = audit.Sink
+
+// NewFileSink creates a sink that appends each event as a JSON line to a file.
+//
+// Example:
+//
+//	options.Sinks = []pbaudit.Sink{pbaudit.NewFileSink("/var/log/app/audit.jsonl")}
+func NewFileSink(path string) Sink {
+	return audit.NewFileSink(path)
+}
+
+// WebhookOptions configures delivery of audit events to an HTTP endpoint.
+type WebhookOptions struct {
+	URL     string            // Endpoint receiving POST requests with JSON batches (required)
+	Secret  string            // HMAC-SHA256 signing key (required)
+	Headers map[string]string // Extra request headers (e.g. Authorization)
+
+	// Batching
+	BatchSize     int           // Maximum number of events per request (default: 100)
+	FlushInterval time.Duration // Maximum time an event waits before being sent (default: 5s)
+	Timeout       time.Duration // Timeout of a single request (default: 10s)
+
+	// Retries
+	InitialBackoff time.Duration // Delay after the first failed attempt, doubled per failure (default: 1s)
+	MaxBackoff     time.Duration // Maximum delay between attempts (default: 5m)
+	MaxAttempts    int           // Attempts per batch before it is moved to <QueueDir>/failed (default: 0 = retry forever)
+	QueueDir       string        // Persistent retry queue directory (default: pb_data/audit_webhook)
+}
+
+// NewWebhookSink creates a sink that POSTs audit events to an HTTP endpoint
+// as signed JSON batches: {"events": [...]}.
+//
+// SIGNATURE:
+// Each request carries X-PB-Audit-Timestamp (Unix seconds) and
+// X-PB-Audit-Signature: "sha256=" + hex(HMAC-SHA256(Secret, timestamp + "." + body)).
+// Receivers should recompute the signature over the raw body and reject old
+// timestamps. X-PB-Audit-Batch identifies the batch across retries, so
+// receivers can deduplicate.
+//
+// DELIVERY:
+// Every batch is stored in QueueDir before it is sent and removed once the
+// endpoint answers with 2xx. Failed batches are retried in order with
+// exponential backoff, also after a restart. 4xx responses (except 408, 425
+// and 429) are not retried; the batch is moved to <QueueDir>/failed and
+// reported through Options.OnSinkError.
+//
+// Example:
+//
+//	webhook, err := pbaudit.NewWebhookSink(app, pbaudit.WebhookOptions{
+//	    URL:    "https://collector.example.com/audit",
+//	    Secret: os.Getenv("AUDIT_WEBHOOK_SECRET"),
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	options.Sinks = append(options.Sinks, webhook)
+func NewWebhookSink(app core.App, options WebhookOptions) (Sink, error) {
+	return audit.NewWebhookSink(app, audit.WebhookOptions{
+		URL:            options.URL,
+		Secret:         options.Secret,
+		Headers:        options.Headers,
+		BatchSize:      options.BatchSize,
+		FlushInterval:  options.FlushInterval,
+		Timeout:        options.Timeout,
+		InitialBackoff: options.InitialBackoff,
+		MaxBackoff:     options.MaxBackoff,
+		MaxAttempts:    options.MaxAttempts,
+		QueueDir:       options.QueueDir,
+	})
+}
+
+// SyslogNetwork is the transport used to reach the syslog server.
+type SyslogNetwork string
+
+const (
+	// SyslogUDP sends one message per datagram (RFC 5426, default).
+	SyslogUDP SyslogNetwork = "udp"
+
+	// SyslogTCP sends messages over TCP with octet-counting framing (RFC 6587).
+	SyslogTCP SyslogNetwork = "tcp"
+
+	// SyslogTLS sends messages over TLS with octet-counting framing (RFC 5425).
+	SyslogTLS SyslogNetwork = "tls"
+)
+
+// SyslogOptions configures delivery of audit events to a syslog server.
+type SyslogOptions struct {
+	Network   SyslogNetwork // Transport (default: SyslogUDP)
+	Address   string        // Server address as host:port (required)
+	TLSConfig *tls.Config   // TLS configuration for SyslogTLS (default: system roots, server name from Address)
+	Facility  *int          // Syslog facility 0-23 (default: 13, log audit)
+	Severity  *int          // Syslog severity 0-7 (default: 5, notice)
+	AppName   string        // APP-NAME header field (default: "pocketbase")
+	Hostname  string        // HOSTNAME header field (default: os.Hostname())
+	SDID      string        // Structured data ID as name@<your IANA enterprise number> (required)
+	Timeout   time.Duration // Dial and write timeout (default: 5s)
+}
+
+// NewSyslogSink creates a sink that sends each audit event as an RFC 5424
+// syslog message.
+//
+// MESSAGE FORMAT:
+//
+//	<109>1 2024-05-01T12:00:00.000000Z host pocketbase 4242 update [audit@12345 event_id="..." event_type="update" collection="posts" record="abc123" actor="u1" ip="203.0.113.7" method="PATCH" request_id="..."] update posts/abc123
+//
+// MSGID is the event type and the structured data element carries the event
+// ID, event type, collection, record, actor, IP, method, request ID and,
+// for batch operations, batch ID and index.
+// Empty values are left out.
+//
+// SDID must carry your organization's IANA private enterprise number; there
+// is no default, since the RFC 5424 examples use the documentation-only
+// number 32473. Facility and Severity are pointers so that 0 (kern, emerg)
+// can be set; nil keeps the default.
+//
+// Writes are synchronous, so with TCP or TLS, Setup requires Async to keep
+// the network off the request path.
+//
+// Example:
+//
+//	syslog, err := pbaudit.NewSyslogSink(pbaudit.SyslogOptions{
+//	    Network: pbaudit.SyslogTLS,
+//	    Address: "siem.example.com:6514",
+//	    SDID:    "audit@12345",
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	options.Sinks = append(options.Sinks, syslog)
+func NewSyslogSink(options SyslogOptions) (Sink, error) {
+	return audit.NewSyslogSink(audit.SyslogOptions{
+		Network:   audit.SyslogNetwork(options.Network),
+		Address:   options.Address,
+		TLSConfig: options.TLSConfig,
+		Facility:  options.Facility,
+		Severity:  options.Severity,
+		AppName:   options.AppName,
+		Hostname:  options.Hostname,
+		SDID:      options.SDID,
+		Timeout:   options.Timeout,
+	})
 }
 
 // Options configures the behavior of audit logging.
@@ -62,9 +418,33 @@ type Options struct {
 	CollectionName string // Name for audit logs collection (default: "audit_logs")
 
 	// What to log
-	LogRequestEvents bool // Log API request events (default: true)
-	LogSuccessEvents bool // Log database success events (default: true)
-	LogAuthEvents    bool // Log authentication events (default: true)
+	LogRequestEvents  bool // Log API request events (default: true)
+	LogSuccessEvents  bool // Log database success events (default: true)
+	LogAuthEvents     bool // Log authentication events (default: true)
+	LogFileDownloads  bool // Log downloads of protected files, and of any file in ReadCollections (default: true)
+	LogSchemaEvents   bool // Log collection create, update and delete events with a schema diff (default: true)
+	LogSettingsEvents bool // Log application settings changes with secrets masked; OAuth2 providers are collection options, see LogSchemaEvents (default: true)
+	LogBackupEvents   bool // Log backup create, upload, download, delete and restore (default: true)
+
+	// Auth collections
+	// Authentication events are logged for every auth collection, including
+	// _superusers. AuthCollections limits them to the listed collections and
+	// ExcludeAuthCollections skips collections (exclusions win).
+	//
+	// Example:
+	//   AuthCollections:        []string{"_superusers", "staff"},
+	//   ExcludeAuthCollections: []string{"service_accounts"},
+	AuthCollections        []string // (default: nil = all auth collections)
+	ExcludeAuthCollections []string // (default: nil)
+
+	// Read access
+	// View and list requests of the listed collections are logged as view
+	// and list events with the returned record IDs, the result count, the
+	// filter/sort/expand query parameters and the actor (no snapshots).
+	//
+	// Example:
+	//   ReadCollections: []string{"patients", "medical_records"},
+	ReadCollections []string // (default: nil = no read events)
 
 	// Optional filtering
 	// EventFilter allows custom filtering logic for events
@@ -81,8 +461,38 @@ type Options struct {
 	// Retention policy for automatic cleanup (nil = no cleanup)
 	Retention *RetentionPolicy
 
+	// Asynchronous batched writing (nil = write synchronously in the request)
+	Async *AsyncOptions
+
+	// Destinations
+	// Sinks receive every audit event in addition to the audit collection,
+	// which is always written. A failing sink doesn't affect the others.
+	Sinks []Sink
+	// OnSinkError is called when a sink fails to write a batch of events,
+	// in addition to the error logged via app.Logger() (default: nil)
+	OnSinkError func(sink string, events []Event, err error)
+
+	// Tamper evidence
+	// HashChain links every audit record to the previous one through a SHA-256
+	// hash chain (prev_hash, hash). Use VerifyChain to detect altered or
+	// deleted records. Retention rules are rejected when it is enabled.
+	HashChain bool // (default: false)
+
+	// Sensitive data
+	// Redaction rules are applied to before/after snapshots and the changes
+	// diff before they are stored. A nil Redaction gets the default rules;
+	// set DisableRedaction to store snapshots unredacted.
+	Redaction        []RedactionRule // (default: DefaultRedactionRules())
+	RedactionSalt    string          // Salt for RedactHash (required if any rule uses it)
+	DisableRedaction bool            // Don't redact anything, not even the default rules (default: false)
+
 	// Logging
-	LogToConsole bool // Enable console logging (default: true)
+	// All diagnostics go through app.Logger(), so they respect the PocketBase
+	// log settings and show up in the admin log viewer. Warnings and errors
+	// (e.g. failed audit writes) are always logged; LogToConsole controls the
+	// informational messages (setup summary, retention runs) and the per-event
+	// debug lines.
+	LogToConsole bool // (default: true)
 }
 
 // DefaultOptions returns sensible defaults for audit logging.
@@ -92,16 +502,28 @@ type Options struct {
 //   - LogRequestEvents: true (track API operations)
 //   - LogSuccessEvents: true (track database operations)
 //   - LogAuthEvents: true (track authentication)
+//   - LogFileDownloads: true (track protected file downloads)
+//   - LogSchemaEvents: true (track collection schema changes)
+//   - LogSettingsEvents: true (track settings changes)
+//   - LogBackupEvents: true (track backups and restores)
+//   - ReadCollections: nil (don't track reads)
 //   - EventFilter: nil (log all events)
-//   - LogToConsole: true (enable logging)
+//   - Async: nil (write synchronously)
+//   - Redaction: DefaultRedactionRules() (mask password/token/secret fields)
+//   - LogToConsole: true (log informational messages)
 func DefaultOptions() Options {
 	return Options{
-		CollectionName:   "audit_logs",
-		LogRequestEvents: true,
-		LogSuccessEvents: true,
-		LogAuthEvents:    true,
-		EventFilter:      nil,
-		LogToConsole:     true,
+		CollectionName:    "audit_logs",
+		LogRequestEvents:  true,
+		LogSuccessEvents:  true,
+		LogAuthEvents:     true,
+		LogFileDownloads:  true,
+		LogSchemaEvents:   true,
+		LogSettingsEvents: true,
+		LogBackupEvents:   true,
+		EventFilter:       nil,
+		Redaction:         DefaultRedactionRules(),
+		LogToConsole:      true,
 	}
 }
 
@@ -113,6 +535,7 @@ func DefaultOptions() Options {
 // - Non-destructive: Only creates collection if it doesn't exist
 // - Preserves customizations: Won't overwrite API rules after initial setup
 // - Always registers hooks: Even if collection already exists
+// - Registers the "audit" CLI command (audit export)
 //
 // PARAMETERS:
 //   - app: PocketBase application instance
@@ -149,12 +572,33 @@ func Setup(app *pocketbase.PocketBase, options Options) error {
 
 	// Convert public Options to internal Options
 	internalOpts := audit.Options{
-		CollectionName:   options.CollectionName,
-		LogRequestEvents: options.LogRequestEvents,
-		LogSuccessEvents: options.LogSuccessEvents,
-		LogAuthEvents:    options.LogAuthEvents,
-		EventFilter:      options.EventFilter,
-		LogToConsole:     options.LogToConsole,
+		CollectionName:         options.CollectionName,
+		LogRequestEvents:       options.LogRequestEvents,
+		LogSuccessEvents:       options.LogSuccessEvents,
+		LogAuthEvents:          options.LogAuthEvents,
+		LogFileDownloads:       options.LogFileDownloads,
+		LogSchemaEvents:        options.LogSchemaEvents,
+		LogSettingsEvents:      options.LogSettingsEvents,
+		LogBackupEvents:        options.LogBackupEvents,
+		AuthCollections:        options.AuthCollections,
+		ExcludeAuthCollections: options.ExcludeAuthCollections,
+		ReadCollections:        options.ReadCollections,
+		EventFilter:            options.EventFilter,
+		Sinks:                  options.Sinks,
+		OnSinkError:            options.OnSinkError,
+		HashChain:              options.HashChain,
+		RedactionSalt:          options.RedactionSalt,
+		LogToConsole:           options.LogToConsole,
+	}
+
+	// Convert redaction rules
+	for _, rule := range options.Redaction {
+		internalOpts.Redaction = append(internalOpts.Redaction, audit.RedactionRule{
+			Collections: rule.Collections,
+			Fields:      rule.Fields,
+			Action:      audit.RedactAction(rule.Action),
+			KeepLast:    rule.KeepLast,
+		})
 	}
 
 	// Convert retention policy if set
@@ -168,8 +612,35 @@ func Setup(app *pocketbase.PocketBase, options Options) error {
 			MaxRecords: options.Retention.MaxRecords,
 			Interval:   interval,
 		}
+		for _, rule := range options.Retention.Rules {
+			internalOpts.Retention.Rules = append(internalOpts.Retention.Rules, audit.RetentionRule{
+				Collections: rule.Collections,
+				EventTypes:  rule.EventTypes,
+				MaxAge:      rule.MaxAge,
+			})
+		}
+		if archive := options.Retention.Archive; archive != nil {
+			internalOpts.Retention.Archive = &audit.ArchivePolicy{
+				Prefix:     archive.Prefix,
+				Filesystem: archive.Filesystem,
+			}
+		}
+	}
+
+	// Convert async options if set
+	if options.Async != nil {
+		internalOpts.Async = &audit.AsyncOptions{
+			QueueSize:     options.Async.QueueSize,
+			BatchSize:     options.Async.BatchSize,
+			FlushInterval: options.Async.FlushInterval,
+			Overflow:      audit.OverflowPolicy(options.Async.Overflow),
+			SpillPath:     options.Async.SpillPath,
+		}
 	}
 
+	// Register the "audit" CLI command (e.g. ./app audit export)
+	app.RootCmd.AddCommand(audit.NewCommand(app, internalOpts))
+
 	// Initialize after app bootstrap
 	app.OnBootstrap().BindFunc(func(e *core.BootstrapEvent) error {
 		// Wait for bootstrap to complete
@@ -183,6 +654,131 @@ func Setup(app *pocketbase.PocketBase, options Options) error {
 	return nil
 }
 
+// ChainReport is the result of verifying the audit hash chain.
+type ChainReport struct {
+	Valid      bool   // True if no broken link was found
+	Checked    int    // Number of hashed records verified
+	Skipped    int    // Records written before the hash chain was enabled
+	AnchorHash string // prev_hash of the first hashed record (older records were purged, e.g. by retention)
+	BrokenAt   string // ID of the first record that failed verification
+	Reason     string // Why verification failed
+}
+
+// VerifyChain walks the audit collection in insertion order and reports the
+// first broken link of the hash chain.
+//
+// A link is broken if a record's contents no longer match its hash (the record
+// was edited) or its prev_hash doesn't match the previous record's hash (a
+// record was deleted or inserted).
+//
+// Example:
+//
+//	report, err := pbaudit.VerifyChain(app, "audit_logs")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	if !report.Valid {
+//	    log.Printf("audit log tampered at %s: %s", report.BrokenAt, report.Reason)
+//	}
+func VerifyChain(app core.App, collectionName string) (*ChainReport, error) {
+	report, err := audit.VerifyChain(app, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChainReport{
+		Valid:      report.Valid,
+		Checked:    report.Checked,
+		Skipped:    report.Skipped,
+		AnchorHash: report.AnchorHash,
+		BrokenAt:   report.BrokenAt,
+		Reason:     report.Reason,
+	}, nil
+}
+
+// MiddlewareId is the hook ID of the audit middleware. Unbind it to exclude
+// single routes of an audited group:
+//
+//	g.GET("/health", health).Unbind(pbaudit.MiddlewareId)
+const MiddlewareId = audit.MiddlewareId
+
+// MiddlewareOptions configures the audit middleware for custom routes.
+type MiddlewareOptions struct {
+	// collection_name of the events (default: "_custom"). AnnotateRecord
+	// overrides it per request.
+	Collection string
+
+	// Skip returns true for requests that should not be logged (default: nil)
+	Skip func(e *core.RequestEvent) bool
+
+	// Enrich adds business fields after the handler ran, e.g. from the
+	// request body or the request store (default: nil)
+	Enrich func(e *core.RequestEvent, fields map[string]any)
+}
+
+// Middleware returns a router middleware that logs every request to the
+// routes it is bound to as a custom_request event.
+//
+// The event carries the method, path, route pattern, actor, IP, response
+// status and duration. Business fields attached with Annotate or by the
+// Enrich hook are stored in metadata.fields. Requests are logged after the
+// handler returned, including failed ones (with the error).
+//
+// Example:
+//
+//	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+//	    se.Router.POST("/api/invoices/{id}/approve", func(e *core.RequestEvent) error {
+//	        invoice, err := approveInvoice(e)
+//	        if err != nil {
+//	            return err
+//	        }
+//	        pbaudit.AnnotateRecord(e, "invoices", invoice.Id)
+//	        pbaudit.Annotate(e, "amount", invoice.GetFloat("amount"))
+//	        return e.JSON(http.StatusOK, invoice)
+//	    }).Bind(pbaudit.Middleware(pbaudit.MiddlewareOptions{}))
+//
+//	    return se.Next()
+//	})
+func Middleware(options MiddlewareOptions) *hook.Handler[*core.RequestEvent] {
+	return audit.NewMiddleware(audit.MiddlewareOptions{
+		Collection: options.Collection,
+		Skip:       options.Skip,
+		Enrich:     options.Enrich,
+	})
+}
+
+// RouteGroup is a router or route group that sub-groups can be created on
+// (e.g. se.Router or a *router.RouterGroup).
+type RouteGroup interface {
+	Group(prefix string) *router.RouterGroup[*core.RequestEvent]
+}
+
+// Group creates a route group with the audit middleware bound, so every
+// route registered on it is logged.
+//
+// Example:
+//
+//	billing := pbaudit.Group(se.Router, "/api/billing", pbaudit.MiddlewareOptions{Collection: "invoices"})
+//	billing.POST("/refunds", issueRefund)
+//	billing.POST("/invoices/{id}/approve", approveInvoice)
+func Group(parent RouteGroup, prefix string, options MiddlewareOptions) *router.RouterGroup[*core.RequestEvent] {
+	group := parent.Group(prefix)
+	group.Bind(Middleware(options))
+	return group
+}
+
+// Annotate attaches a business field (e.g. an invoice amount or a refund
+// reason) to the custom_request event of the current request.
+func Annotate(e *core.RequestEvent, key string, value any) {
+	audit.Annotate(e, key, value)
+}
+
+// AnnotateRecord sets the collection and record the custom_request event of
+// the current request refers to, so it shows up in the record's history.
+func AnnotateRecord(e *core.RequestEvent, collectionName string, recordID string) {
+	audit.AnnotateRecord(e, collectionName, recordID)
+}
+
 // applyDefaults fills in default values for missing options.
 func applyDefaults(options Options) Options {
 	defaults := DefaultOptions()
@@ -193,15 +789,55 @@ func applyDefaults(options Options) Options {
 
 	// For boolean fields, we can't distinguish between false and unset,
 	// so we check if ALL logging options are false, which is unlikely to be intentional
-	if !options.LogRequestEvents && !options.LogSuccessEvents && !options.LogAuthEvents {
+	if !loggingEnabled(options) {
 		options.LogRequestEvents = defaults.LogRequestEvents
 		options.LogSuccessEvents = defaults.LogSuccessEvents
 		options.LogAuthEvents = defaults.LogAuthEvents
+		options.LogFileDownloads = defaults.LogFileDownloads
+		options.LogSchemaEvents = defaults.LogSchemaEvents
+		options.LogSettingsEvents = defaults.LogSettingsEvents
+		options.LogBackupEvents = defaults.LogBackupEvents
+	}
+
+	// Redact with the default rules unless rules were given or redaction is off
+	if options.DisableRedaction {
+		options.Redaction = nil
+	} else if options.Redaction == nil {
+		options.Redaction = defaults.Redaction
+	}
+
+	// Fill in async defaults (copy so the caller's struct isn't modified)
+	if options.Async != nil {
+		async := *options.Async
+		if async.QueueSize <= 0 {
+			async.QueueSize = 10000
+		}
+		if async.BatchSize <= 0 {
+			async.BatchSize = 100
+		}
+		if async.FlushInterval <= 0 {
+			async.FlushInterval = time.Second
+		}
+		if async.Overflow == "" {
+			async.Overflow = OverflowBlock
+		}
+		options.Async = &async
 	}
 
 	return options
 }
 
+// loggingEnabled reports whether any of the logging options is enabled.
+func loggingEnabled(options Options) bool {
+	return options.LogRequestEvents ||
+		options.LogSuccessEvents ||
+		options.LogAuthEvents ||
+		options.LogFileDownloads ||
+		options.LogSchemaEvents ||
+		options.LogSettingsEvents ||
+		options.LogBackupEvents
+}
+
 // validateOptions validates the provided options.
 func validateOptions(options Options) error {
 	if options.CollectionName == "" {
@@ -209,10 +845,46 @@ func validateOptions(options Options) error {
 	}
 
 	// At least one logging option should be enabled
-	if !options.LogRequestEvents && !options.LogSuccessEvents && !options.LogAuthEvents {
+	if !loggingEnabled(options) {
 		return fmt.Errorf("at least one logging option must be enabled")
 	}
 
+	for _, rule := range options.Redaction {
+		switch rule.Action {
+		case RedactDrop, RedactMask, RedactLastN:
+		case RedactHash:
+			if options.RedactionSalt == "" {
+				return fmt.Errorf("redaction salt is required for the %q action", RedactHash)
+			}
+		default:
+			return fmt.Errorf("unknown redaction action %q", rule.Action)
+		}
+	}
+
+	if options.Retention != nil {
+		if options.Retention.MaxAge < 0 {
+			return fmt.Errorf("retention max age cannot be negative")
+		}
+		for _, rule := range options.Retention.Rules {
+			if rule.MaxAge < 0 {
+				return fmt.Errorf("retention rule max age cannot be negative")
+			}
+		}
+		// Rules purge records from the middle of the log, which VerifyChain
+		// can't tell apart from deleted records
+		if options.HashChain && len(options.Retention.Rules) > 0 {
+			return fmt.Errorf("retention rules cannot be combined with the hash chain")
+		}
+	}
+
+	if options.Async != nil {
+		switch options.Async.Overflow {
+		case OverflowBlock, OverflowDrop, OverflowSpill:
+		default:
+			return fmt.Errorf("unknown async overflow policy %q", options.Async.Overflow)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+)
+
+// ArchivePolicy configures archiving of audit records before retention deletes them.
+type ArchivePolicy struct {
+	Prefix     string                                         // Key prefix of archive files (default: "audit_archive")
+	Filesystem func(app core.App) (*filesystem.System, error) // Storage for archives (default: local pb_data)
+}
+
+// archiveManifest describes an archive file. It is stored next to the archive
+// as <name>.manifest.json.
+type archiveManifest struct {
+	File           string `json:"file"`
+	Collection     string `json:"collection"`
+	Records        int    `json:"records"`
+	SHA256         string `json:"sha256"` // Checksum of the compressed archive file
+	FirstTimestamp string `json:"first_timestamp"`
+	LastTimestamp  string `json:"last_timestamp"`
+	Created        string `json:"created"`
+}
+
+// archiveRecords writes a batch of audit records to a gzip-compressed JSONL
+// archive with a manifest.
+//
+// The archive is uploaded before the manifest, and the caller must only delete
+// the records if this function returns nil.
+//
+// The file name is derived from the batch (timestamp of its first record and a
+// digest of its record IDs), so archiving the same batch again is a no-op: if
+// the delete after a successful archive fails, the next run selects the same
+// records and finds their manifest instead of writing a duplicate archive. An
+// archive without manifest (interrupted upload) is overwritten.
+//
+// FILES:
+//   - <prefix>/<collection>_<time>_<digest>.jsonl.gz
+//   - <prefix>/<collection>_<time>_<digest>.manifest.json
+func archiveRecords(app core.App, options Options, records []*core.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	archive := options.Retention.Archive
+
+	prefix := archive.Prefix
+	if prefix == "" {
+		prefix = "audit_archive"
+	}
+	name := archiveName(options.CollectionName, records)
+	archiveKey := path.Join(prefix, name+".jsonl.gz")
+	manifestKey := path.Join(prefix, name+".manifest.json")
+
+	fsys, err := archiveFilesystem(app, archive)
+	if err != nil {
+		return fmt.Errorf("failed to open archive filesystem: %w", err)
+	}
+	defer fsys.Close()
+
+	// Already archived by a run whose delete failed
+	exists, err := fsys.Exists(manifestKey)
+	if err != nil {
+		return fmt.Errorf("failed to check archive manifest: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	for _, record := range records {
+		if err := encoder.Encode(record.FieldsData()); err != nil {
+			return fmt.Errorf("failed to encode audit record %s: %w", record.Id, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress audit archive: %w", err)
+	}
+
+	content := buf.Bytes()
+	sum := sha256.Sum256(content)
+
+	manifest, err := json.MarshalIndent(archiveManifest{
+		File:           path.Base(archiveKey),
+		Collection:     options.CollectionName,
+		Records:        len(records),
+		SHA256:         hex.EncodeToString(sum[:]),
+		FirstTimestamp: records[0].GetDateTime(AuditLogFields.Timestamp).String(),
+		LastTimestamp:  records[len(records)-1].GetDateTime(AuditLogFields.Timestamp).String(),
+		Created:        time.Now().UTC().Format(time.RFC3339),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode archive manifest: %w", err)
+	}
+
+	if err := fsys.Upload(content, archiveKey); err != nil {
+		return fmt.Errorf("failed to upload audit archive: %w", err)
+	}
+
+	if err := fsys.Upload(manifest, manifestKey); err != nil {
+		return fmt.Errorf("failed to upload archive manifest: %w", err)
+	}
+
+	return nil
+}
+
+// archiveName returns the file name (without extension) of a batch of records:
+// the timestamp of its first record and a digest of its record IDs.
+func archiveName(collectionName string, records []*core.Record) string {
+	digest := sha256.New()
+	for _, record := range records {
+		digest.Write([]byte(record.Id))
+		digest.Write([]byte{0})
+	}
+
+	first := records[0].GetDateTime(AuditLogFields.Timestamp).Time().UTC()
+
+	return fmt.Sprintf("%s_%s_%s", collectionName, first.Format("20060102T150405.000Z"), hex.EncodeToString(digest.Sum(nil))[:16])
+}
+
+// archiveFilesystem opens the configured archive storage (local pb_data by default).
+func archiveFilesystem(app core.App, archive *ArchivePolicy) (*filesystem.System, error) {
+	if archive.Filesystem != nil {
+		return archive.Filesystem(app)
+	}
+	return filesystem.NewLocal(app.DataDir())
+}
@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestArchiveRecordsIdempotent(t *testing.T) {
+	options := testOptions()
+	app := newTestApp(t, options)
+
+	dir := t.TempDir()
+	options.Retention = &RetentionPolicy{
+		MaxAge: time.Hour,
+		Archive: &ArchivePolicy{
+			Filesystem: func(app core.App) (*filesystem.System, error) {
+				return filesystem.NewLocal(dir)
+			},
+		},
+	}
+
+	collection, err := app.FindCollectionByNameOrId("audit_logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []*core.Record
+	for _, recordID := range []string{"a", "b"} {
+		record := core.NewRecord(collection)
+		record.Id = "audit0000000000" + recordID
+		record.Set(AuditLogFields.EventType, EventTypeCreate)
+		record.Set(AuditLogFields.RecordID, recordID)
+		record.Set(AuditLogFields.Timestamp, types.NowDateTime())
+		records = append(records, record)
+	}
+
+	// A failed delete makes the next run archive the same batch again
+	for i := 0; i < 2; i++ {
+		if err := archiveRecords(app, options, records); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A different batch gets its own archive
+	if err := archiveRecords(app, options, records[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := filesystem.NewLocal(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsys.Close()
+
+	files, err := fsys.List("audit_archive/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		keys := make([]string, len(files))
+		for i, file := range files {
+			keys[i] = file.Key
+		}
+		t.Fatalf("expected 2 archives with manifests, got %v", keys)
+	}
+}
@@ -2,6 +2,7 @@ package audit
 
 import (
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/pocketbase/pocketbase"
@@ -9,9 +10,18 @@ import (
 
 // RetentionPolicy configures automatic cleanup of old audit logs.
 type RetentionPolicy struct {
-	MaxAge     time.Duration // Delete records older than this duration (0 = disabled)
-	MaxRecords int           // Keep at most this many records (0 = disabled)
-	Interval   string        // Cron expression for cleanup schedule
+	MaxAge     time.Duration   // Delete records older than this duration (0 = disabled)
+	MaxRecords int             // Keep at most this many records (0 = disabled)
+	Rules      []RetentionRule // Max ages for specific collections/event types (first match wins)
+	Interval   string          // Cron expression for cleanup schedule
+	Archive    *ArchivePolicy  // Archive records before deleting them (nil = no archive)
+}
+
+// RetentionRule sets the max age of the audit records of some collections and event types.
+type RetentionRule struct {
+	Collections []string      // Collections the rule applies to (empty = all collections)
+	EventTypes  []string      // Event types the rule applies to (empty = all event types)
+	MaxAge      time.Duration // Keep matching records this long (0 = keep forever)
 }
 
 // Options holds configuration for audit logging setup.
@@ -20,9 +30,20 @@ type Options struct {
 	CollectionName string // Name for the audit logs collection (default: "audit_logs")
 
 	// What to log
-	LogRequestEvents bool // Log API request events (default: true)
-	LogSuccessEvents bool // Log database success events (default: true)
-	LogAuthEvents    bool // Log authentication events (default: true)
+	LogRequestEvents  bool // Log API request events (default: true)
+	LogSuccessEvents  bool // Log database success events (default: true)
+	LogAuthEvents     bool // Log authentication events (default: true)
+	LogFileDownloads  bool // Log downloads of protected files (default: true)
+	LogSchemaEvents   bool // Log collection create, update and delete events (default: true)
+	LogSettingsEvents bool // Log application settings changes (default: true)
+	LogBackupEvents   bool // Log backup create, upload, download, delete and restore (default: true)
+
+	// Auth collections whose authentication events are logged
+	AuthCollections        []string // Only log these auth collections (empty = all auth collections)
+	ExcludeAuthCollections []string // Never log these auth collections
+
+	// Collections whose view and list requests are logged (empty = no read events)
+	ReadCollections []string
 
 	// Optional filtering
 	// EventFilter allows custom filtering logic for events
@@ -33,19 +54,34 @@ type Options struct {
 	// Retention policy for automatic cleanup (nil = no cleanup)
 	Retention *RetentionPolicy
 
+	// Asynchronous batched writing (nil = write synchronously in the request)
+	Async *AsyncOptions
+
+	// Additional destinations for audit events (the collection is always written)
+	Sinks       []Sink
+	OnSinkError func(sink string, events []Event, err error) // Called when a sink fails (default: console warning)
+
+	// Tamper evidence
+	HashChain bool // Link audit records into a hash chain (default: false)
+
+	// Sensitive data
+	Redaction     []RedactionRule // Redaction rules applied to snapshots before storing
+	RedactionSalt string          // Salt for RedactHash
+
 	// Logging
-	LogToConsole bool // Enable console logging of audit events (default: true)
+	LogToConsole bool // Log informational messages via app.Logger(); warnings are always logged (default: true)
 }
 
 // Initialize sets up audit logging in the correct order.
 //
 // SETUP PROCESS:
 // 1. Check if audit logs collection exists
-// 2. Create collection if needed (non-destructive)
+// 2. Create collection if needed, or add missing fields (non-destructive)
 // 3. Register hooks for tracking operations
 //
 // NON-DESTRUCTIVE BEHAVIOR:
 // - Only creates collection if it doesn't exist
+// - Existing collections only get missing fields added
 // - Sets API rules only on initial creation
 // - Preserves any customizations made after setup
 // - Always registers hooks (even if collection exists)
@@ -58,30 +94,30 @@ type Options struct {
 //   - nil on successful setup
 //   - error if setup fails
 func Initialize(app *pocketbase.PocketBase, options Options) error {
-	if options.LogToConsole {
-		fmt.Println("🚀 START Initializing PocketBase audit logging...")
-	}
+	log := appLogger(app)
 
 	// Check if audit logs collection exists
 	_, err := app.FindCollectionByNameOrId(options.CollectionName)
 	isFirstTimeSetup := err != nil
 
 	if isFirstTimeSetup {
-		if options.LogToConsole {
-			fmt.Println("ℹ️  INFO   Performing first-time setup for audit logs collection...")
-		}
-
 		// Create audit logs collection
 		if err := ensureAuditCollection(app, options.CollectionName); err != nil {
 			return fmt.Errorf("failed to create audit logs collection: %w", err)
 		}
 
 		if options.LogToConsole {
-			fmt.Println("✅ SUCCESS Audit logs collection created")
+			log.Info("Audit logs collection created", "collection", options.CollectionName)
 		}
 	} else {
-		if options.LogToConsole {
-			fmt.Println("ℹ️  INFO   Audit logs collection already exists. Skipping schema modifications.")
+		// Add fields introduced by newer versions (never modifies existing ones)
+		added, err := ensureAuditFields(app, options.CollectionName)
+		if err != nil {
+			return fmt.Errorf("failed to upgrade audit logs collection: %w", err)
+		}
+
+		if options.LogToConsole && len(added) > 0 {
+			log.Info("Added audit log fields", "collection", options.CollectionName, "fields", added)
 		}
 	}
 
@@ -98,15 +134,37 @@ func Initialize(app *pocketbase.PocketBase, options Options) error {
 	}
 
 	if options.LogToConsole {
-		fmt.Println("✅ SUCCESS PocketBase audit logging initialized successfully")
-		fmt.Printf("ℹ️  INFO   - Collection: %s\n", options.CollectionName)
-		fmt.Printf("ℹ️  INFO   - Log request events: %v\n", options.LogRequestEvents)
-		fmt.Printf("ℹ️  INFO   - Log success events: %v\n", options.LogSuccessEvents)
-		fmt.Printf("ℹ️  INFO   - Log auth events: %v\n", options.LogAuthEvents)
+		attrs := []any{
+			"collection", options.CollectionName,
+			"requestEvents", options.LogRequestEvents,
+			"successEvents", options.LogSuccessEvents,
+			"authEvents", options.LogAuthEvents,
+			"readCollections", options.ReadCollections,
+			"fileDownloads", options.LogFileDownloads,
+			"schemaEvents", options.LogSchemaEvents,
+			"settingsEvents", options.LogSettingsEvents,
+			"backupEvents", options.LogBackupEvents,
+			"hashChain", options.HashChain,
+			"redactionRules", len(options.Redaction),
+			"sinks", len(options.Sinks),
+		}
 		if options.Retention != nil {
-			fmt.Printf("ℹ️  INFO   - Retention: maxAge=%v, maxRecords=%d, interval=%s\n",
-				options.Retention.MaxAge, options.Retention.MaxRecords, options.Retention.Interval)
+			attrs = append(attrs, slog.Group("retention",
+				"maxAge", options.Retention.MaxAge.String(),
+				"maxRecords", options.Retention.MaxRecords,
+				"rules", len(options.Retention.Rules),
+				"interval", options.Retention.Interval,
+			))
+		}
+		if options.Async != nil {
+			attrs = append(attrs, slog.Group("async",
+				"queueSize", options.Async.QueueSize,
+				"batchSize", options.Async.BatchSize,
+				"flushInterval", options.Async.FlushInterval.String(),
+				"overflow", options.Async.Overflow,
+			))
 		}
+		log.Info("Audit logging initialized", attrs...)
 	}
 
 	return nil
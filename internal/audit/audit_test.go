@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// testOptions returns options for tests: every event kind enabled, no
+// console logging.
+func testOptions() Options {
+	return Options{
+		CollectionName:    "audit_logs",
+		LogRequestEvents:  true,
+		LogSuccessEvents:  true,
+		LogAuthEvents:     true,
+		LogFileDownloads:  true,
+		LogSchemaEvents:   true,
+		LogSettingsEvents: true,
+		LogBackupEvents:   true,
+	}
+}
+
+// newTestApp bootstraps a PocketBase app in a temporary data directory and
+// initializes audit logging with the given options.
+func newTestApp(t *testing.T, options Options) *pocketbase.PocketBase {
+	t.Helper()
+
+	app := pocketbase.NewWithConfig(pocketbase.Config{
+		DefaultDataDir:  t.TempDir(),
+		HideStartBanner: true,
+	})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatalf("failed to bootstrap app: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = app.ResetBootstrapState()
+	})
+
+	if err := Initialize(app, options); err != nil {
+		t.Fatalf("failed to initialize audit logging: %v", err)
+	}
+
+	return app
+}
+
+// findAuditRecords returns the audit records of an event type, oldest first.
+func findAuditRecords(t *testing.T, app core.App, eventType string) []*core.Record {
+	t.Helper()
+
+	var records []*core.Record
+	err := app.RecordQuery("audit_logs").
+		AndWhere(dbx.HashExp{AuditLogFields.EventType: eventType}).
+		OrderBy("rowid ASC").
+		All(&records)
+	if err != nil {
+		t.Fatalf("failed to load %s audit records: %v", eventType, err)
+	}
+
+	return records
+}
+
+// newTestCollection creates a base collection with a text field "title".
+func newTestCollection(t *testing.T, app core.App, name string) *core.Collection {
+	t.Helper()
+
+	collection := core.NewBaseCollection(name)
+	collection.Fields.Add(&core.TextField{Name: "title"})
+	collection.Fields.Add(&core.AutodateField{Name: "created", OnCreate: true})
+	collection.Fields.Add(&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true})
+	if err := app.Save(collection); err != nil {
+		t.Fatalf("failed to create collection %s: %v", name, err)
+	}
+
+	return collection
+}
+
+// serveTestRequest sends a JSON request with optional headers through the
+// app router, with the middlewares registered in OnServe.
+func serveTestRequest(t *testing.T, app core.App, method string, url string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	router, err := apis.NewRouter(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+
+	serveEvent := &core.ServeEvent{App: app, Router: router}
+	err = app.OnServe().Trigger(serveEvent, func(e *core.ServeEvent) error {
+		mux, err := e.Router.BuildMux()
+		if err != nil {
+			return err
+		}
+
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("content-type", "application/json")
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		mux.ServeHTTP(recorder, request)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to serve %s %s: %v", method, url, err)
+	}
+
+	return recorder
+}
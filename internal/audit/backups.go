@@ -0,0 +1,307 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// backupsCollectionName is the collection_name of backup events
+	// (backups are files, not records of a collection).
+	backupsCollectionName = "_backups"
+
+	// backupsRoutePrefix is the path of the PocketBase backups API.
+	backupsRoutePrefix = "/api/backups"
+
+	// pendingBackupTTL is how long the request info of a backup API request
+	// waits for its backup hook (restores run in the background).
+	pendingBackupTTL = time.Minute
+
+	// restoreMarkerName is the file name of the restore marker in pb_data.
+	restoreMarkerName = "audit_restore_marker.json"
+)
+
+// pendingBackup carries the request info of a backup create or restore API
+// request to the OnBackupCreate/OnBackupRestore hook.
+//
+// PocketBase runs only one backup or restore at a time, so a single slot
+// is enough.
+type pendingBackup struct {
+	mu          sync.Mutex
+	requestInfo map[string]interface{}
+	createdAt   time.Time
+}
+
+// set stores the request info of a backup API request.
+func (p *pendingBackup) set(requestInfo map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requestInfo = requestInfo
+	p.createdAt = time.Now()
+}
+
+// take returns and clears the stored request info (nil if none or stale).
+func (p *pendingBackup) take() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	requestInfo := p.requestInfo
+	p.requestInfo = nil
+
+	if time.Since(p.createdAt) > pendingBackupTTL {
+		return nil
+	}
+	return requestInfo
+}
+
+// restoreMarker is written right before a backup is restored and turned into
+// a backup_restored event after the restart.
+type restoreMarker struct {
+	Name        string            `json:"name"`
+	RequestedAt time.Time         `json:"requested_at"`
+	RequestInfo map[string]string `json:"request_info,omitempty"`
+}
+
+// registerBackupHooks registers hooks for backup operations.
+//
+// Creates and restores are logged from the backup hooks, so automatic and
+// programmatic backups are covered too; API requests lend them their actor
+// and request metadata. Uploads, downloads and deletes only exist as API
+// routes and are logged by a router middleware once they succeeded.
+//
+// A restore replaces the whole pb_data directory, audit log included, and
+// restarts the app. The backup_restore event is logged before the restore
+// (so external sinks receive it) and a marker file is written to pb_data,
+// excluded from the restore. When the restarted app serves again, the
+// marker is logged as a backup_restored event: the point where the audit
+// history was rewound. Other commands (e.g. migrations) leave it in place.
+//
+// EVENTS:
+// - backup_create, backup_upload, backup_download, backup_delete
+// - backup_restore: a restore was started (lost from the collection by the restore)
+// - backup_restored: first event after a restore (discontinuity marker)
+func registerBackupHooks(app *pocketbase.PocketBase, logger *logger) error {
+	// Hook: Backup API routes
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		// Log the marker of a restore that happened before this start
+		logger.logRestoreMarker()
+
+		se.Router.BindFunc(logger.backupMiddleware)
+		return se.Next()
+	})
+
+	// Hook: Backup create (API, automatic backups and app.CreateBackup)
+	app.OnBackupCreate().BindFunc(func(e *core.BackupEvent) error {
+		requestInfo := logger.pendingBackup.take()
+
+		err := e.Next()
+		if err == nil {
+			logger.logBackupEvent(EventTypeBackupCreate, e.Name, requestInfo)
+		}
+		return err
+	})
+
+	// Hook: Backup restore (API and app.RestoreBackup)
+	app.OnBackupRestore().BindFunc(func(e *core.BackupEvent) error {
+		requestInfo := logger.pendingBackup.take()
+
+		logger.logBackupEvent(EventTypeBackupRestore, e.Name, requestInfo)
+
+		// Keep the marker in place while the restore swaps pb_data
+		e.Exclude = append(e.Exclude, restoreMarkerName)
+
+		markerPath := restoreMarkerPath(logger.app)
+		if err := writeRestoreMarker(markerPath, e.Name, requestInfo); err != nil {
+			appLogger(logger.app).Warn("Failed to write audit restore marker",
+				"backup", e.Name, "path", markerPath, "error", err)
+		}
+
+		// A successful restore restarts the app and doesn't return here
+		err := e.Next()
+		if err != nil {
+			_ = os.Remove(markerPath)
+		}
+		return err
+	})
+
+	return nil
+}
+
+// backupMiddleware logs backup uploads, downloads and deletes, and hands the
+// request info of create and restore requests to the backup hooks.
+func (l *logger) backupMiddleware(e *core.RequestEvent) error {
+	eventType, name := backupRoute(e)
+	if eventType == "" {
+		return e.Next()
+	}
+
+	requestInfo := extractRequestInfo(e)
+	applyFileTokenActor(l.app, requestInfo, e)
+
+	switch eventType {
+	case EventTypeBackupCreate:
+		// Creates run in the request; clear the slot if the hook never ran
+		l.pendingBackup.set(requestInfo)
+		defer l.pendingBackup.take()
+		return e.Next()
+	case EventTypeBackupRestore:
+		// Restores run in the background after the response
+		l.pendingBackup.set(requestInfo)
+		return e.Next()
+	}
+
+	err := e.Next()
+	if err != nil {
+		return err
+	}
+
+	if eventType == EventTypeBackupUpload {
+		name = uploadedBackupName(e)
+	}
+	l.logBackupEvent(eventType, name, requestInfo)
+
+	return nil
+}
+
+// backupRoute returns the backup event type and backup name of a request
+// to the backups API. Returns an empty event type for other requests
+// (including the backups list).
+func backupRoute(e *core.RequestEvent) (string, string) {
+	path := strings.TrimSuffix(e.Request.URL.Path, "/")
+	if path != backupsRoutePrefix && !strings.HasPrefix(path, backupsRoutePrefix+"/") {
+		return "", ""
+	}
+
+	key := e.Request.PathValue("key")
+
+	switch e.Request.Method {
+	case http.MethodPost:
+		switch {
+		case path == backupsRoutePrefix:
+			return EventTypeBackupCreate, ""
+		case path == backupsRoutePrefix+"/upload":
+			return EventTypeBackupUpload, ""
+		case key != "" && strings.HasSuffix(path, "/restore"):
+			return EventTypeBackupRestore, key
+		}
+	case http.MethodGet:
+		if key != "" {
+			return EventTypeBackupDownload, key
+		}
+	case http.MethodDelete:
+		if key != "" {
+			return EventTypeBackupDelete, key
+		}
+	}
+
+	return "", ""
+}
+
+// uploadedBackupName returns the filename of an uploaded backup.
+func uploadedBackupName(e *core.RequestEvent) string {
+	if e.Request.MultipartForm == nil {
+		return ""
+	}
+
+	files := e.Request.MultipartForm.File["file"]
+	if len(files) == 0 {
+		return ""
+	}
+
+	return files[0].Filename
+}
+
+// logBackupEvent logs a backup event with the backup name as record_id.
+//
+// PARAMETERS:
+//   - eventType: Type of event (see event type constants)
+//   - name: Name (key) of the backup file
+//   - requestInfo: Request metadata of the API request (nil = not an API request)
+func (l *logger) logBackupEvent(eventType string, name string, requestInfo map[string]interface{}) {
+	if requestInfo == nil {
+		requestInfo = make(map[string]interface{})
+	}
+	requestInfo[AuditLogFields.RecordID] = name
+
+	if err := l.logEvent(nil, nil, backupsCollectionName, eventType, requestInfo); err != nil {
+		appLogger(l.app).Warn("Failed to log backup event",
+			"eventType", eventType, "backup", name, "error", err)
+	}
+}
+
+// logRestoreMarker logs the backup_restored event of a restore marker left
+// by the previous process, if any, and removes the marker.
+func (l *logger) logRestoreMarker() {
+	path := restoreMarkerPath(l.app)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			appLogger(l.app).Warn("Failed to read audit restore marker", "path", path, "error", err)
+		}
+		return
+	}
+
+	if err := os.Remove(path); err != nil {
+		appLogger(l.app).Warn("Failed to remove audit restore marker", "path", path, "error", err)
+	}
+
+	var marker restoreMarker
+	if err := json.Unmarshal(raw, &marker); err != nil {
+		appLogger(l.app).Warn("Invalid audit restore marker", "path", path, "error", err)
+		return
+	}
+
+	requestInfo := make(map[string]interface{}, len(marker.RequestInfo)+1)
+	for key, value := range marker.RequestInfo {
+		requestInfo[key] = value
+	}
+	requestInfo[AuditLogFields.Metadata] = map[string]any{
+		"requested_at": marker.RequestedAt.UTC().Format(time.RFC3339),
+		"reason":       "backup restored, audit records after the backup was created are gone",
+	}
+
+	l.logBackupEvent(EventTypeBackupRestored, marker.Name, requestInfo)
+
+	if l.options.LogToConsole {
+		appLogger(l.app).Info("Logged audit restore marker", "backup", marker.Name)
+	}
+}
+
+// writeRestoreMarker writes the restore marker of a backup that is about to
+// be restored.
+func writeRestoreMarker(path string, name string, requestInfo map[string]interface{}) error {
+	marker := restoreMarker{
+		Name:        name,
+		RequestedAt: time.Now(),
+		RequestInfo: make(map[string]string, len(requestInfo)),
+	}
+	for key, value := range requestInfo {
+		if s, ok := value.(string); ok && key != AuditLogFields.RecordID {
+			marker.RequestInfo[key] = s
+		}
+	}
+
+	raw, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, raw, 0o600)
+}
+
+// restoreMarkerPath returns the path of the restore marker of an app.
+func restoreMarkerPath(app core.App) string {
+	return filepath.Join(app.DataDir(), restoreMarkerName)
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestRestoreMarker(t *testing.T) {
+	app := pocketbase.NewWithConfig(pocketbase.Config{
+		DefaultDataDir:  t.TempDir(),
+		HideStartBanner: true,
+	})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = app.ResetBootstrapState()
+	})
+
+	// Marker left by the process that restored the backup
+	markerPath := restoreMarkerPath(app)
+	requestInfo := map[string]interface{}{AuditLogFields.ActorID: "admin1"}
+	if err := writeRestoreMarker(markerPath, "backup.zip", requestInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Initialize(app, testOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Commands that bootstrap the app without serving leave the marker alone
+	if records := findAuditRecords(t, app, EventTypeBackupRestored); len(records) != 0 {
+		t.Fatalf("expected no backup_restored event before serving, got %d", len(records))
+	}
+	if _, err := os.Stat(markerPath); err != nil {
+		t.Fatalf("expected the marker to be kept until the app serves: %v", err)
+	}
+
+	serveTestRequest(t, app, http.MethodGet, "/api/health", "", nil)
+
+	records := findAuditRecords(t, app, EventTypeBackupRestored)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 backup_restored event, got %d", len(records))
+	}
+	if name := records[0].GetString(AuditLogFields.RecordID); name != "backup.zip" {
+		t.Fatalf("expected the backup name backup.zip, got %q", name)
+	}
+	if actor := records[0].GetString(AuditLogFields.ActorID); actor != "admin1" {
+		t.Fatalf("expected the actor of the restore, got %q", actor)
+	}
+	if _, err := os.Stat(markerPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the marker to be removed, got %v", err)
+	}
+}
+
+func TestRestoreMarkerExcludedFromRestore(t *testing.T) {
+	app := newTestApp(t, testOptions())
+
+	errRestoreStopped := errors.New("restore stopped")
+
+	event := &core.BackupEvent{Name: "backup.zip", Exclude: []string{core.LocalBackupsDirName}}
+	event.App = app
+	err := app.OnBackupRestore().Trigger(event, func(e *core.BackupEvent) error {
+		if !slices.Contains(e.Exclude, restoreMarkerName) {
+			t.Errorf("expected the marker to be excluded from the restore, got %v", e.Exclude)
+		}
+		if _, err := os.Stat(restoreMarkerPath(app)); err != nil {
+			t.Errorf("expected the marker to be written before the restore: %v", err)
+		}
+		return errRestoreStopped
+	})
+	if !errors.Is(err, errRestoreStopped) {
+		t.Fatalf("expected the restore error, got %v", err)
+	}
+
+	// A failed restore doesn't leave a marker behind
+	if _, err := os.Stat(restoreMarkerPath(app)); !os.IsNotExist(err) {
+		t.Fatalf("expected the marker to be removed after the failed restore, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"sync"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+const (
+	// batchCollectionName is the collection_name of batch summary events
+	// (a batch can span several collections).
+	batchCollectionName = "_batch"
+
+	// batchStoreKey is the request event store key holding the batch context.
+	// PocketBase copies the store of the batch request to every sub-request,
+	// so all of them share the same *batchContext.
+	batchStoreKey = "pbAuditBatch"
+
+	// batchIndexStoreKey is the store key holding the index of a sub-request.
+	batchIndexStoreKey = "pbAuditBatchIndex"
+)
+
+// batchContext identifies a batch request, numbers its sub-requests and
+// buffers their events until the batch finished.
+type batchContext struct {
+	id   string
+	base *core.RequestEvent // The batch request itself (not a sub-request)
+
+	mu     sync.Mutex
+	next   int
+	events []Event
+	done   bool
+}
+
+// buffer adds an event to the batch. Returns false once the batch finished.
+func (b *batchContext) buffer(event Event) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.done {
+		return false
+	}
+
+	b.events = append(b.events, event)
+	return true
+}
+
+// finish ends buffering and returns the buffered events.
+func (b *batchContext) finish() []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.done = true
+	events := b.events
+	b.events = nil
+	return events
+}
+
+// registerBatchHooks groups the operations of /api/batch requests.
+//
+// The batch request gets a batch ID that every audit event of its
+// sub-requests carries in batch_id, together with the position of the
+// sub-request in batch_index (0-based, in request order). Success events
+// get them through the correlator like the request ID.
+//
+// The sub-requests run in the batch transaction, so their events are
+// buffered (see logger.dispatch) and written once the batch request
+// returned, followed by a batch summary event with the number of
+// operations, the method and URL of each one and whether the batch
+// transaction was committed.
+//
+// These hooks must be registered before the request and correlation hooks,
+// so every sub-request is numbered before it is logged.
+func registerBatchHooks(app *pocketbase.PocketBase, logger *logger) error {
+	// Hook: Batch request
+	app.OnBatchRequest().BindFunc(func(e *core.BatchRequestEvent) error {
+		batch := &batchContext{
+			id:   security.RandomString(20),
+			base: e.RequestEvent,
+		}
+		e.Set(batchStoreKey, batch)
+
+		// Assign the request ID now, so the sub-requests inherit it as well
+		requestID(e.RequestEvent)
+
+		logger.batches.Store(batch.id, batch)
+		err := e.Next()
+		logger.batches.Delete(batch.id)
+
+		// Write the events of the sub-requests now that the transaction
+		// released the write connection
+		if events := batch.finish(); len(events) > 0 {
+			if writeErr := logger.write(events); writeErr != nil {
+				appLogger(logger.app).Warn("Failed to log batch operations",
+					"batch", batch.id, "events", len(events), "error", writeErr)
+			}
+		}
+
+		requests := make([]map[string]any, 0, len(e.Batch))
+		for _, request := range e.Batch {
+			requests = append(requests, map[string]any{
+				"method": request.Method,
+				"url":    request.URL,
+			})
+		}
+
+		metadata := map[string]any{
+			"operations": len(e.Batch),
+			"requests":   requests,
+			"status":     "committed",
+		}
+		if err != nil {
+			metadata["status"] = "failed"
+			metadata["error"] = err.Error()
+		}
+
+		requestInfo := extractRequestInfo(e.RequestEvent)
+		requestInfo[AuditLogFields.BatchID] = batch.id
+		requestInfo[AuditLogFields.Metadata] = metadata
+
+		if logErr := logger.logEvent(nil, nil, batchCollectionName, EventTypeBatch, requestInfo); logErr != nil {
+			appLogger(logger.app).Warn("Failed to log batch summary",
+				"batch", batch.id, "operations", len(e.Batch), "error", logErr)
+		}
+
+		return err
+	})
+
+	// Hook: Sub-requests - number them in execution order
+	number := func(e *core.RecordRequestEvent) error {
+		batchInfo(e.RequestEvent)
+		return e.Next()
+	}
+
+	app.OnRecordCreateRequest().BindFunc(number)
+	app.OnRecordUpdateRequest().BindFunc(number)
+	app.OnRecordDeleteRequest().BindFunc(number)
+
+	return nil
+}
+
+// applyBatchInfo adds the batch ID and operation index of a batch
+// sub-request to request info.
+func applyBatchInfo(requestInfo map[string]interface{}, e *core.RequestEvent) {
+	if id, index, ok := batchInfo(e); ok {
+		requestInfo[AuditLogFields.BatchID] = id
+		requestInfo[AuditLogFields.BatchIndex] = index
+	}
+}
+
+// batchInfo returns the batch ID and index of a batch sub-request.
+//
+// The index is assigned on the first call for a sub-request and kept in its
+// event store. Returns false for requests that are not part of a batch.
+func batchInfo(e *core.RequestEvent) (string, int, bool) {
+	batch, ok := e.Get(batchStoreKey).(*batchContext)
+	if !ok || batch.base == e {
+		return "", 0, false
+	}
+
+	if index, ok := e.Get(batchIndexStoreKey).(int); ok {
+		return batch.id, index, true
+	}
+
+	batch.mu.Lock()
+	index := batch.next
+	batch.next++
+	batch.mu.Unlock()
+
+	e.Set(batchIndexStoreKey, index)
+
+	return batch.id, index, true
+}
@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestBatchRequestSync(t *testing.T) {
+	app := newTestApp(t, testOptions())
+
+	app.Settings().Batch.Enabled = true
+	app.Settings().Batch.Timeout = 3
+	if err := app.Save(app.Settings()); err != nil {
+		t.Fatal(err)
+	}
+
+	collection := newTestCollection(t, app, "notes")
+	collection.CreateRule = types.Pointer("")
+	if err := app.Save(collection); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"requests": [
+		{"method": "POST", "url": "/api/collections/notes/records", "body": {"title": "a"}},
+		{"method": "POST", "url": "/api/collections/notes/records", "body": {"title": "b"}}
+	]}`
+
+	// The events of the sub-requests must not wait for the batch transaction
+	started := time.Now()
+	response := serveTestRequest(t, app, http.MethodPost, "/api/batch", body, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", response.Code, response.Body.String())
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("expected the batch to complete without waiting, took %s", elapsed)
+	}
+
+	summaries := findAuditRecords(t, app, EventTypeBatch)
+	if len(summaries) != 1 {
+		t.Fatalf("expected 1 batch summary, got %d", len(summaries))
+	}
+	batchID := summaries[0].GetString(AuditLogFields.BatchID)
+
+	for _, eventType := range []string{EventTypeCreateRequest, EventTypeCreate} {
+		records := findAuditRecords(t, app, eventType)
+		if len(records) != 2 {
+			t.Fatalf("expected 2 %s events, got %d", eventType, len(records))
+		}
+
+		for i, record := range records {
+			if record.GetString(AuditLogFields.BatchID) != batchID || record.GetInt(AuditLogFields.BatchIndex) != i {
+				t.Errorf("expected %s event %d in batch %s at index %d, got %s at %d", eventType, i, batchID, i,
+					record.GetString(AuditLogFields.BatchID), record.GetInt(AuditLogFields.BatchIndex))
+			}
+		}
+	}
+
+	// The buffered events are written before the summary
+	var last []*core.Record
+	if err := app.RecordQuery("audit_logs").OrderBy("rowid DESC").Limit(1).All(&last); err != nil {
+		t.Fatal(err)
+	}
+	if len(last) != 1 || last[0].Id != summaries[0].Id {
+		t.Errorf("expected the batch summary to be the last audit record")
+	}
+}
@@ -0,0 +1,314 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// chainVerifyPageSize is the number of records loaded per page during verification.
+const chainVerifyPageSize = 500
+
+// chainExcludedFields are the audit record fields that are not covered by the hash.
+// id, created and updated are assigned by PocketBase while saving, after the
+// hash has been computed.
+var chainExcludedFields = map[string]bool{
+	"id":                    true,
+	AuditLogFields.Created:  true,
+	AuditLogFields.Updated:  true,
+	AuditLogFields.PrevHash: true,
+	AuditLogFields.Hash:     true,
+}
+
+// hashChain links audit records into a tamper-evident, append-only chain.
+//
+// Each record stores the hash of the previous record (prev_hash) and its own
+// hash, computed over a canonical serialization of its fields plus prev_hash.
+// Editing, inserting or deleting a record in the middle of the chain breaks
+// the link to the following record, which VerifyChain detects.
+//
+// Records must be inserted one at a time in chain order, so every write to
+// the audit collection goes through insert. The chain head is read in the
+// same transaction as the inserts; PocketBase runs write transactions on a
+// single connection, so concurrent inserts are serialized by the database.
+type hashChain struct {
+	collectionName string
+}
+
+// newHashChain creates a hash chain for the given audit collection.
+func newHashChain(collectionName string) *hashChain {
+	return &hashChain{collectionName: collectionName}
+}
+
+// insert saves audit records in a single transaction, linking each one to the
+// previous record.
+//
+// If app is the app of a running transaction, the records are inserted in
+// that transaction and commit or roll back with it.
+func (c *hashChain) insert(app core.App, records []*core.Record) error {
+	return app.RunInTransaction(func(txApp core.App) error {
+		head, err := c.head(txApp)
+		if err != nil {
+			return fmt.Errorf("failed to load audit hash chain head: %w", err)
+		}
+
+		for _, record := range records {
+			hash, err := computeRecordHash(record, head)
+			if err != nil {
+				return err
+			}
+			record.Set(AuditLogFields.PrevHash, head)
+			record.Set(AuditLogFields.Hash, hash)
+
+			if err := txApp.Save(record); err != nil {
+				return err
+			}
+			head = hash
+		}
+		return nil
+	})
+}
+
+// head returns the hash of the most recently inserted audit record
+// (empty if there is none).
+func (c *hashChain) head(app core.App) (string, error) {
+	last := &core.Record{}
+	err := app.RecordQuery(c.collectionName).
+		OrderBy("rowid DESC").
+		Limit(1).
+		One(last)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return last.GetString(AuditLogFields.Hash), nil
+}
+
+// computeRecordHash computes the chain hash of an audit record.
+//
+// CANONICAL SERIALIZATION:
+//   - All collection fields except id, created, updated, prev_hash and hash
+//   - Empty values are left out, so fields added by later versions (or custom
+//     fields) don't change the hash of older records
+//   - Dates use the stored format, JSON values are re-encoded with sorted keys
+//   - The result is a JSON object with sorted keys
+//
+// hash = hex(sha256(prev_hash + "\n" + canonical JSON))
+func computeRecordHash(record *core.Record, prevHash string) (string, error) {
+	canonical := make(map[string]any)
+
+	for _, field := range record.Collection().Fields {
+		name := field.GetName()
+		if chainExcludedFields[name] {
+			continue
+		}
+
+		value, err := canonicalValue(record.Get(name))
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize field %q: %w", name, err)
+		}
+		if value != nil {
+			canonical[name] = value
+		}
+	}
+
+	// encoding/json sorts map keys, which makes the output canonical
+	raw, err := json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(prevHash+"\n"), raw...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalValue normalizes a record field value for hashing.
+// Returns nil for empty values.
+func canonicalValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if v == "" {
+			return nil, nil
+		}
+		return v, nil
+	case bool:
+		if !v {
+			return nil, nil
+		}
+		return v, nil
+	case float64:
+		if v == 0 {
+			return nil, nil
+		}
+		return v, nil
+	case int:
+		if v == 0 {
+			return nil, nil
+		}
+		return v, nil
+	case []string:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		return v, nil
+	case types.DateTime:
+		if v.IsZero() {
+			return nil, nil
+		}
+		return v.String(), nil
+	case types.JSONRaw:
+		if len(v) == 0 {
+			return nil, nil
+		}
+		var decoded any
+		if err := json.Unmarshal(v, &decoded); err != nil {
+			return nil, err
+		}
+		if decoded == nil {
+			return nil, nil
+		}
+		return decoded, nil
+	default:
+		// Any other type is normalized through a JSON round trip
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var decoded any
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	}
+}
+
+// ChainReport is the result of verifying the audit hash chain.
+type ChainReport struct {
+	Valid      bool   // True if no broken link was found
+	Checked    int    // Number of hashed records verified
+	Skipped    int    // Records written before the chain was enabled
+	AnchorHash string // prev_hash of the first hashed record (its predecessor was deleted, e.g. by retention)
+	BrokenAt   string // ID of the first record that failed verification
+	Reason     string // Why verification failed
+}
+
+// VerifyChain walks the audit collection in insertion order and reports the
+// first broken link of the hash chain.
+//
+// CHECKS:
+//   - Each record's hash matches its recomputed hash (detects edited records)
+//   - Each record's prev_hash matches the previous record's hash (detects
+//     deleted or inserted records)
+//
+// Records without a hash before the first hashed record are skipped (written
+// before the chain was enabled). The first hashed record's prev_hash cannot be
+// checked if older records were purged, and is reported as AnchorHash.
+//
+// PARAMETERS:
+//   - app: PocketBase application instance
+//   - collectionName: Name of the audit logs collection
+//
+// RETURNS:
+//   - Verification report
+//   - error if the collection cannot be read
+func VerifyChain(app core.App, collectionName string) (*ChainReport, error) {
+	report := &ChainReport{Valid: true}
+
+	started := false
+	prevHash := ""
+
+	for offset := 0; ; offset += chainVerifyPageSize {
+		var records []*core.Record
+		err := app.RecordQuery(collectionName).
+			OrderBy("rowid ASC").
+			Offset(int64(offset)).
+			Limit(chainVerifyPageSize).
+			All(&records)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audit records: %w", err)
+		}
+
+		for _, record := range records {
+			hash := record.GetString(AuditLogFields.Hash)
+			recordPrevHash := record.GetString(AuditLogFields.PrevHash)
+
+			if !started {
+				if hash == "" {
+					report.Skipped++
+					continue
+				}
+				started = true
+				report.AnchorHash = recordPrevHash
+				prevHash = recordPrevHash
+			}
+
+			if hash == "" {
+				return report.broken(record, "record has no hash"), nil
+			}
+
+			if recordPrevHash != prevHash {
+				return report.broken(record, "prev_hash does not match the previous record (record deleted or inserted)"), nil
+			}
+
+			expected, err := computeRecordHash(record, recordPrevHash)
+			if err != nil {
+				return nil, err
+			}
+			if expected != hash {
+				return report.broken(record, "hash does not match the record contents (record modified)"), nil
+			}
+
+			prevHash = hash
+			report.Checked++
+		}
+
+		if len(records) < chainVerifyPageSize {
+			break
+		}
+	}
+
+	return report, nil
+}
+
+// broken marks the report as failed at the given record.
+func (r *ChainReport) broken(record *core.Record, reason string) *ChainReport {
+	r.Valid = false
+	r.BrokenAt = record.Id
+	r.Reason = reason
+	return r
+}
+
+// insertRecords saves a batch of audit records in a single transaction,
+// through the hash chain if it is enabled.
+//
+// If app is the app of a running transaction (e.g. the txApp of a hook), the
+// records are saved in that transaction instead of waiting for it.
+func insertRecords(app core.App, chain *hashChain, records []*core.Record) error {
+	if chain != nil {
+		return chain.insert(app, records)
+	}
+
+	if len(records) == 1 {
+		return app.Save(records[0])
+	}
+
+	return app.RunInTransaction(func(txApp core.App) error {
+		for _, record := range records {
+			if err := txApp.Save(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,219 @@
+package audit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestComputeRecordHash(t *testing.T) {
+	app := newTestApp(t, testOptions())
+
+	collection, err := app.FindCollectionByNameOrId("audit_logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newAuditRecord := func() *core.Record {
+		record := core.NewRecord(collection)
+		record.Set(AuditLogFields.EventType, EventTypeCreate)
+		record.Set(AuditLogFields.CollectionName, "notes")
+		record.Set(AuditLogFields.RecordID, "abc")
+		return record
+	}
+
+	base, err := computeRecordHash(newAuditRecord(), "prev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []struct {
+		name     string
+		prevHash string
+		modify   func(record *core.Record)
+		same     bool
+	}{
+		{"identical record", "prev", func(record *core.Record) {}, true},
+		{"id is not hashed", "prev", func(record *core.Record) { record.Id = "other" }, true},
+		{"empty fields are not hashed", "prev", func(record *core.Record) { record.Set(AuditLogFields.RequestIP, "") }, true},
+		{"different prev_hash", "other", func(record *core.Record) {}, false},
+		{"modified field", "prev", func(record *core.Record) { record.Set(AuditLogFields.RecordID, "abd") }, false},
+		{"added field", "prev", func(record *core.Record) { record.Set(AuditLogFields.RequestIP, "10.0.0.1") }, false},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			record := newAuditRecord()
+			s.modify(record)
+
+			hash, err := computeRecordHash(record, s.prevHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (hash == base) != s.same {
+				t.Errorf("expected same hash %v, got %s (base %s)", s.same, hash, base)
+			}
+		})
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	scenarios := []struct {
+		name string
+		// tamper changes the audit records (in insertion order) and returns
+		// the index of the record expected to fail (-1 = chain valid)
+		tamper         func(t *testing.T, app core.App, records []*core.Record) int
+		expectedReason string
+		expectedAnchor func(records []*core.Record) string
+	}{
+		{
+			name: "intact chain",
+			tamper: func(t *testing.T, app core.App, records []*core.Record) int {
+				return -1
+			},
+		},
+		{
+			name: "modified record",
+			tamper: func(t *testing.T, app core.App, records []*core.Record) int {
+				execSQL(t, app, "UPDATE audit_logs SET record_id = 'forged' WHERE id = {:id}", records[1].Id)
+				return 1
+			},
+			expectedReason: "record modified",
+		},
+		{
+			name: "deleted record",
+			tamper: func(t *testing.T, app core.App, records []*core.Record) int {
+				execSQL(t, app, "DELETE FROM audit_logs WHERE id = {:id}", records[1].Id)
+				return 2
+			},
+			expectedReason: "record deleted or inserted",
+		},
+		{
+			name: "purged oldest records (anchor)",
+			tamper: func(t *testing.T, app core.App, records []*core.Record) int {
+				execSQL(t, app, "DELETE FROM audit_logs WHERE id = {:id}", records[0].Id)
+				return -1
+			},
+			expectedAnchor: func(records []*core.Record) string {
+				return records[0].GetString(AuditLogFields.Hash)
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			// Only the record events below are chained
+			options := testOptions()
+			options.HashChain = true
+			options.LogSchemaEvents = false
+			app := newTestApp(t, options)
+
+			collection := newTestCollection(t, app, "notes")
+			for _, title := range []string{"a", "b", "c"} {
+				record := core.NewRecord(collection)
+				record.Set("title", title)
+				if err := app.Save(record); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			records := findAuditRecords(t, app, EventTypeCreate)
+			if len(records) != 3 {
+				t.Fatalf("expected 3 audit records, got %d", len(records))
+			}
+
+			brokenIndex := s.tamper(t, app, records)
+
+			report, err := VerifyChain(app, "audit_logs")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if brokenIndex < 0 {
+				if !report.Valid {
+					t.Fatalf("expected a valid chain, broken at %s: %s", report.BrokenAt, report.Reason)
+				}
+			} else {
+				if report.Valid {
+					t.Fatal("expected a broken chain")
+				}
+				if report.BrokenAt != records[brokenIndex].Id {
+					t.Errorf("expected break at record %d (%s), got %s", brokenIndex, records[brokenIndex].Id, report.BrokenAt)
+				}
+				if !strings.Contains(report.Reason, s.expectedReason) {
+					t.Errorf("expected reason containing %q, got %q", s.expectedReason, report.Reason)
+				}
+			}
+
+			expectedAnchor := ""
+			if s.expectedAnchor != nil {
+				expectedAnchor = s.expectedAnchor(records)
+			}
+			if report.AnchorHash != expectedAnchor {
+				t.Errorf("expected anchor %q, got %q", expectedAnchor, report.AnchorHash)
+			}
+		})
+	}
+}
+
+func TestHashChainInTransaction(t *testing.T) {
+	options := testOptions()
+	options.HashChain = true
+	app := newTestApp(t, options)
+
+	collection, err := app.FindCollectionByNameOrId("audit_logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := newHashChain("audit_logs")
+
+	newAuditRecord := func(recordID string) *core.Record {
+		record := core.NewRecord(collection)
+		record.Set(AuditLogFields.EventType, EventTypeCreate)
+		record.Set(AuditLogFields.CollectionName, "notes")
+		record.Set(AuditLogFields.RecordID, recordID)
+		record.Set(AuditLogFields.Timestamp, types.NowDateTime())
+		return record
+	}
+
+	// Inserting through the app of a running transaction must not wait for it,
+	// and a rollback must not advance the chain
+	err = app.RunInTransaction(func(txApp core.App) error {
+		if err := chain.insert(txApp, []*core.Record{newAuditRecord("rolled-back")}); err != nil {
+			return err
+		}
+		return errTestRollback
+	})
+	if err != errTestRollback {
+		t.Fatalf("expected the test rollback error, got %v", err)
+	}
+
+	if err := chain.insert(app, []*core.Record{newAuditRecord("committed")}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifyChain(app, "audit_logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid || report.Checked != 1 || report.AnchorHash != "" {
+		t.Errorf("expected a valid chain of 1 record without anchor, got %+v", report)
+	}
+}
+
+// errTestRollback rolls back test transactions.
+var errTestRollback = errors.New("test rollback")
+
+// execSQL runs a raw statement against the app database, bypassing the hooks.
+func execSQL(t *testing.T, app core.App, query string, id string) {
+	t.Helper()
+
+	if _, err := app.DB().NewQuery(query).Bind(dbx.Params{"id": id}).Execute(); err != nil {
+		t.Fatalf("failed to execute %q: %v", query, err)
+	}
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// collectionSinkName is the name of the built-in collection sink.
+const collectionSinkName = "collection"
+
+// collectionSink writes audit events to the audit logs collection.
+//
+// It is always the first sink. Collection-specific concerns live here: the
+// user relation, the retention expiry and the hash chain.
+type collectionSink struct {
+	app     *pocketbase.PocketBase
+	options Options
+
+	// chain links audit records into a hash chain (nil = disabled)
+	chain *hashChain
+}
+
+// newCollectionSink creates the collection sink.
+func newCollectionSink(app *pocketbase.PocketBase, options Options) *collectionSink {
+	s := &collectionSink{app: app, options: options}
+
+	if options.HashChain {
+		s.chain = newHashChain(options.CollectionName)
+	}
+
+	return s
+}
+
+// Name implements Sink.
+func (s *collectionSink) Name() string {
+	return collectionSinkName
+}
+
+// Write implements Sink.
+//
+// The events are inserted in a single transaction. If the transaction fails,
+// they are saved one by one so a single invalid record doesn't lose the whole
+// batch.
+func (s *collectionSink) Write(events []Event) error {
+	collection, err := s.app.FindCachedCollectionByNameOrId(s.options.CollectionName)
+	if err != nil {
+		return fmt.Errorf("failed to find audit logs collection: %w", err)
+	}
+
+	records := make([]*core.Record, len(events))
+	for i, event := range events {
+		records[i] = s.newRecord(collection, event)
+	}
+
+	err = insertRecords(s.app, s.chain, records)
+	if err == nil || len(records) == 1 {
+		return err
+	}
+
+	appLogger(s.app).Warn("Failed to write audit batch, retrying records individually",
+		"records", len(records), "error", err)
+
+	var errs []error
+	for _, record := range records {
+		// The rolled back transaction may have marked the record as saved
+		record.MarkAsNew()
+
+		if err := insertRecords(s.app, s.chain, []*core.Record{record}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// newRecord converts an event to an audit record.
+func (s *collectionSink) newRecord(collection *core.Collection, event Event) *core.Record {
+	record := core.NewRecord(collection)
+	record.Id = event.ID
+
+	record.Set(AuditLogFields.EventType, event.EventType)
+	record.Set(AuditLogFields.CollectionName, event.CollectionName)
+	record.Set(AuditLogFields.Timestamp, event.Timestamp)
+
+	// Only set if not empty (create_request events may not have ID yet)
+	if event.RecordID != "" {
+		record.Set(AuditLogFields.RecordID, event.RecordID)
+	}
+
+	// Only set the user relation if it's a valid user ID
+	if event.User != "" && s.isValidUser(event.User) {
+		record.Set(AuditLogFields.User, event.User)
+	}
+
+	record.Set(AuditLogFields.ActorCollection, event.ActorCollection)
+	record.Set(AuditLogFields.ActorID, event.ActorID)
+	record.Set(AuditLogFields.ActorEmail, event.ActorEmail)
+
+	record.Set(AuditLogFields.AuthMethod, event.AuthMethod)
+	record.Set(AuditLogFields.RequestMethod, event.RequestMethod)
+	record.Set(AuditLogFields.RequestIP, event.RequestIP)
+	record.Set(AuditLogFields.RequestURL, event.RequestURL)
+	record.Set(AuditLogFields.RequestID, event.RequestID)
+	record.Set(AuditLogFields.BatchID, event.BatchID)
+	if event.BatchIndex != nil {
+		record.Set(AuditLogFields.BatchIndex, *event.BatchIndex)
+	}
+
+	// Set the retention expiry resolved from the retention rules
+	if s.options.Retention != nil {
+		if maxAge := s.options.Retention.maxAgeFor(event.CollectionName, event.EventType); maxAge > 0 {
+			record.Set(AuditLogFields.ExpiresAt, event.Timestamp.Add(maxAge))
+		}
+	}
+
+	if event.Before != nil {
+		record.Set(AuditLogFields.BeforeChanges, event.Before)
+	}
+	if event.After != nil {
+		record.Set(AuditLogFields.AfterChanges, event.After)
+	}
+	if event.Changes != nil {
+		record.Set(AuditLogFields.Changes, event.Changes)
+	}
+	if event.Metadata != nil {
+		record.Set(AuditLogFields.Metadata, event.Metadata)
+	}
+
+	return record
+}
+
+// isValidUser checks if a user ID exists in the users collection.
+//
+// This is necessary because authenticated users might be admins/superusers
+// who are not in the regular users collection. We only want to set the
+// user relation field if it's a valid user record.
+//
+// PARAMETERS:
+//   - userID: User ID to check
+//
+// RETURNS:
+//   - true if user exists in users collection
+//   - false if user doesn't exist (e.g., admin/superuser)
+func (s *collectionSink) isValidUser(userID string) bool {
+	_, err := s.app.FindRecordById("users", userID)
+	return err == nil
+}
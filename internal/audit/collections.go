@@ -2,12 +2,35 @@ package audit
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/types"
 )
 
+// auditIndex describes a single index on the audit logs collection.
+type auditIndex struct {
+	name    string
+	columns []string
+}
+
+// auditIndexes lists the indexes created for the audit logs collection.
+var auditIndexes = []auditIndex{
+	{"idx_audit_collection_name", []string{AuditLogFields.CollectionName}},
+	{"idx_audit_record_id", []string{AuditLogFields.RecordID}},
+	{"idx_audit_timestamp", []string{AuditLogFields.Timestamp}},
+	{"idx_audit_user", []string{AuditLogFields.User}},
+	{"idx_audit_event_type", []string{AuditLogFields.EventType}},
+	// Composite indexes for common queries
+	{"idx_audit_collection_timestamp", []string{AuditLogFields.CollectionName, AuditLogFields.Timestamp}},
+	{"idx_audit_user_timestamp", []string{AuditLogFields.User, AuditLogFields.Timestamp}},
+	{"idx_audit_request_id", []string{AuditLogFields.RequestID}},
+	{"idx_audit_actor", []string{AuditLogFields.ActorCollection, AuditLogFields.ActorID}},
+	{"idx_audit_expires_at", []string{AuditLogFields.ExpiresAt}},
+	{"idx_audit_batch", []string{AuditLogFields.BatchID, AuditLogFields.BatchIndex}},
+}
+
 // ensureAuditCollection creates the audit logs collection if it doesn't exist.
 //
 // BEHAVIOR:
@@ -20,13 +43,20 @@ import (
 // - collection_name: Text field for collection name
 // - record_id: Text field for record ID
 // - user: Relation to users collection (preserved on user delete)
+// - actor_collection, actor_id, actor_email: Text fields for actors of any auth collection
 // - auth_method: Text field for authentication method
 // - request_method: Text field for HTTP method
 // - request_ip: Text field for client IP
 // - request_url: Text field for request path
+// - request_id: Text field linking request and success events
+// - batch_id, batch_index: Text and number fields grouping batch operations
 // - timestamp: Date field for event time
+// - expires_at: Date field with the retention expiry of the record
 // - before_changes: JSON field for record state before operation
 // - after_changes: JSON field for record state after operation
+// - changes: JSON field with the field-level diff for update events
+// - metadata: JSON field with event-specific details
+// - prev_hash, hash: Text fields for the tamper-evident hash chain
 //
 // PARAMETERS:
 //   - app: PocketBase application instance
@@ -46,107 +76,17 @@ func ensureAuditCollection(app *pocketbase.PocketBase, collectionName string) er
 	// Create new base collection
 	collection := core.NewBaseCollection(collectionName)
 
-	// Add event_type select field
-	collection.Fields.Add(&core.SelectField{
-		Name:      AuditLogFields.EventType,
-		Required:  true,
-		MaxSelect: 1,
-		Values:    AllEventTypes,
-	})
-
-	// Add collection_name field
-	collection.Fields.Add(&core.TextField{
-		Name:     AuditLogFields.CollectionName,
-		Required: true,
-		Max:      255,
-	})
-
-	// Add record_id field (not required - create_request events don't have IDs yet)
-	collection.Fields.Add(&core.TextField{
-		Name:     AuditLogFields.RecordID,
-		Required: false,
-		Max:      255,
-	})
-
-	// Get users collection for relation field
-	usersCollection, err := app.FindCollectionByNameOrId("users")
+	fields, err := auditFields(app)
 	if err != nil {
-		return fmt.Errorf("users collection not found: %w", err)
+		return err
+	}
+	for _, field := range fields {
+		collection.Fields.Add(field)
 	}
-
-	// Add user relation field (not required - admins aren't in users collection)
-	// CascadeDelete is false to preserve audit logs even if user is deleted
-	collection.Fields.Add(&core.RelationField{
-		Name:          AuditLogFields.User,
-		Required:      false,
-		MaxSelect:     1,
-		CollectionId:  usersCollection.Id,
-		CascadeDelete: false,
-	})
-
-	// Add auth_method field for authentication events
-	collection.Fields.Add(&core.TextField{
-		Name: AuditLogFields.AuthMethod,
-		Max:  100,
-	})
-
-	// Add request_method field (GET, POST, PUT, DELETE, etc.)
-	collection.Fields.Add(&core.TextField{
-		Name: AuditLogFields.RequestMethod,
-		Max:  20,
-	})
-
-	// Add request_ip field for client IP address
-	collection.Fields.Add(&core.TextField{
-		Name: AuditLogFields.RequestIP,
-		Max:  100,
-	})
-
-	// Add request_url field for request path
-	collection.Fields.Add(&core.TextField{
-		Name: AuditLogFields.RequestURL,
-		Max:  2000,
-	})
-
-	// Add timestamp field
-	collection.Fields.Add(&core.DateField{
-		Name:     AuditLogFields.Timestamp,
-		Required: true,
-	})
-
-	// Add before_changes JSON field for storing record state before operation
-	collection.Fields.Add(&core.JSONField{
-		Name:    AuditLogFields.BeforeChanges,
-		MaxSize: 2000000, // 2MB limit
-	})
-
-	// Add after_changes JSON field for storing record state after operation
-	collection.Fields.Add(&core.JSONField{
-		Name:    AuditLogFields.AfterChanges,
-		MaxSize: 2000000, // 2MB limit
-	})
-
-	// Add auto-generated timestamp fields
-	collection.Fields.Add(&core.AutodateField{
-		Name:     AuditLogFields.Created,
-		OnCreate: true,
-	})
-	collection.Fields.Add(&core.AutodateField{
-		Name:     AuditLogFields.Updated,
-		OnCreate: true,
-		OnUpdate: true,
-	})
 
 	// Create indexes for faster querying
-	collection.Indexes = []string{
-		fmt.Sprintf("CREATE INDEX idx_audit_collection_name ON %s (%s)", collectionName, AuditLogFields.CollectionName),
-		fmt.Sprintf("CREATE INDEX idx_audit_record_id ON %s (%s)", collectionName, AuditLogFields.RecordID),
-		fmt.Sprintf("CREATE INDEX idx_audit_timestamp ON %s (%s)", collectionName, AuditLogFields.Timestamp),
-		fmt.Sprintf("CREATE INDEX idx_audit_user ON %s (%s)", collectionName, AuditLogFields.User),
-		fmt.Sprintf("CREATE INDEX idx_audit_event_type ON %s (%s)", collectionName, AuditLogFields.EventType),
-		// Composite indexes for common queries
-		fmt.Sprintf("CREATE INDEX idx_audit_collection_timestamp ON %s (%s, %s)", collectionName, AuditLogFields.CollectionName, AuditLogFields.Timestamp),
-		fmt.Sprintf("CREATE INDEX idx_audit_user_timestamp ON %s (%s, %s)", collectionName, AuditLogFields.User, AuditLogFields.Timestamp),
+	for _, index := range auditIndexes {
+		collection.Indexes = append(collection.Indexes, index.sql(collectionName))
 	}
 
 	// Set API rules for admin-only access (only on initial creation)
@@ -164,3 +104,237 @@ func ensureAuditCollection(app *pocketbase.PocketBase, collectionName string) er
 
 	return nil
 }
+
+// ensureAuditFields adds fields introduced by newer pb-audit versions to an
+// existing audit logs collection.
+//
+// BEHAVIOR:
+// - Additive only: fields that already exist are never modified or removed
+// - New event types are added to the event_type select values (existing values are kept)
+// - Indexes are only added for the fields created by this call
+// - API rules and any custom fields are left untouched
+//
+// RETURNS:
+//   - Names of the fields that were added or extended
+//   - error if the collection cannot be updated
+func ensureAuditFields(app *pocketbase.PocketBase, collectionName string) ([]string, error) {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := auditFields(app)
+	if err != nil {
+		return nil, err
+	}
+
+	added := make(map[string]bool)
+	var addedNames []string
+	for _, field := range fields {
+		if collection.Fields.GetByName(field.GetName()) != nil {
+			continue
+		}
+		collection.Fields.Add(field)
+		added[field.GetName()] = true
+		addedNames = append(addedNames, field.GetName())
+	}
+
+	// Add event types introduced by newer versions to the select field
+	if field, ok := collection.Fields.GetByName(AuditLogFields.EventType).(*core.SelectField); ok && !added[field.Name] {
+		extended := false
+		for _, eventType := range AllEventTypes {
+			if !containsString(field.Values, eventType) {
+				field.Values = append(field.Values, eventType)
+				extended = true
+			}
+		}
+		if extended {
+			addedNames = append(addedNames, field.Name)
+		}
+	}
+
+	if len(addedNames) == 0 {
+		return nil, nil
+	}
+
+	for _, index := range auditIndexes {
+		if index.coveredBy(added) {
+			collection.Indexes = append(collection.Indexes, index.sql(collectionName))
+		}
+	}
+
+	if err := app.Save(collection); err != nil {
+		return nil, fmt.Errorf("failed to add audit log fields: %w", err)
+	}
+
+	return addedNames, nil
+}
+
+// auditFields builds the field definitions of the audit logs collection.
+func auditFields(app *pocketbase.PocketBase) ([]core.Field, error) {
+	// Get users collection for relation field
+	usersCollection, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return nil, fmt.Errorf("users collection not found: %w", err)
+	}
+
+	return []core.Field{
+		// event_type select field
+		&core.SelectField{
+			Name:      AuditLogFields.EventType,
+			Required:  true,
+			MaxSelect: 1,
+			Values:    AllEventTypes,
+		},
+
+		// collection_name field
+		&core.TextField{
+			Name:     AuditLogFields.CollectionName,
+			Required: true,
+			Max:      255,
+		},
+
+		// record_id field (not required - create_request events don't have IDs yet)
+		&core.TextField{
+			Name:     AuditLogFields.RecordID,
+			Required: false,
+			Max:      255,
+		},
+
+		// user relation field (not required - admins aren't in users collection)
+		// CascadeDelete is false to preserve audit logs even if user is deleted
+		&core.RelationField{
+			Name:          AuditLogFields.User,
+			Required:      false,
+			MaxSelect:     1,
+			CollectionId:  usersCollection.Id,
+			CascadeDelete: false,
+		},
+
+		// actor fields for any auth record, including superusers and auth
+		// collections other than users (plain text, so they survive deletes)
+		&core.TextField{
+			Name: AuditLogFields.ActorCollection,
+			Max:  255,
+		},
+		&core.TextField{
+			Name: AuditLogFields.ActorID,
+			Max:  255,
+		},
+		&core.TextField{
+			Name: AuditLogFields.ActorEmail,
+			Max:  255,
+		},
+
+		// auth_method field for authentication events
+		&core.TextField{
+			Name: AuditLogFields.AuthMethod,
+			Max:  100,
+		},
+
+		// request_method field (GET, POST, PUT, DELETE, etc.)
+		&core.TextField{
+			Name: AuditLogFields.RequestMethod,
+			Max:  20,
+		},
+
+		// request_ip field for client IP address
+		&core.TextField{
+			Name: AuditLogFields.RequestIP,
+			Max:  100,
+		},
+
+		// request_url field for request path
+		&core.TextField{
+			Name: AuditLogFields.RequestURL,
+			Max:  2000,
+		},
+
+		// request_id field linking request events to their success events
+		&core.TextField{
+			Name: AuditLogFields.RequestID,
+			Max:  requestIDMaxLength,
+		},
+
+		// batch_id and batch_index fields grouping the operations of /api/batch requests
+		&core.TextField{
+			Name: AuditLogFields.BatchID,
+			Max:  100,
+		},
+		&core.NumberField{
+			Name:    AuditLogFields.BatchIndex,
+			OnlyInt: true,
+		},
+
+		// timestamp field
+		&core.DateField{
+			Name:     AuditLogFields.Timestamp,
+			Required: true,
+		},
+
+		// expires_at field, resolved from the retention rules when the record is written
+		&core.DateField{
+			Name: AuditLogFields.ExpiresAt,
+		},
+
+		// before_changes JSON field for storing record state before operation
+		&core.JSONField{
+			Name:    AuditLogFields.BeforeChanges,
+			MaxSize: 2000000, // 2MB limit
+		},
+
+		// after_changes JSON field for storing record state after operation
+		&core.JSONField{
+			Name:    AuditLogFields.AfterChanges,
+			MaxSize: 2000000, // 2MB limit
+		},
+
+		// changes JSON field for the field-level diff of update events
+		&core.JSONField{
+			Name:    AuditLogFields.Changes,
+			MaxSize: 2000000, // 2MB limit
+		},
+
+		// metadata JSON field for event-specific details (e.g. failed login reasons)
+		&core.JSONField{
+			Name:    AuditLogFields.Metadata,
+			MaxSize: 2000000, // 2MB limit
+		},
+
+		// prev_hash and hash fields for the tamper-evident hash chain
+		&core.TextField{
+			Name: AuditLogFields.PrevHash,
+			Max:  64,
+		},
+		&core.TextField{
+			Name: AuditLogFields.Hash,
+			Max:  64,
+		},
+
+		// Auto-generated timestamp fields
+		&core.AutodateField{
+			Name:     AuditLogFields.Created,
+			OnCreate: true,
+		},
+		&core.AutodateField{
+			Name:     AuditLogFields.Updated,
+			OnCreate: true,
+			OnUpdate: true,
+		},
+	}, nil
+}
+
+// sql returns the CREATE INDEX statement for the given collection.
+func (i auditIndex) sql(collectionName string) string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", i.name, collectionName, strings.Join(i.columns, ", "))
+}
+
+// coveredBy reports whether every column of the index is in the given set.
+func (i auditIndex) coveredBy(fields map[string]bool) bool {
+	for _, column := range i.columns {
+		if !fields[column] {
+			return false
+		}
+	}
+	return true
+}
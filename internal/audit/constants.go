@@ -33,7 +33,50 @@ const (
 	EventTypeDelete = "delete" // Record successfully deleted
 
 	// Authentication Events
-	EventTypeAuth = "auth" // User authentication (login)
+	EventTypeAuth       = "auth"        // User authentication (login)
+	EventTypeAuthFailed = "auth_failed" // Rejected password, OTP or OAuth2 login attempt
+
+	// Account Lifecycle Events (auth records)
+	EventTypePasswordResetRequest = "password_reset_request" // Password reset email requested
+	EventTypePasswordResetConfirm = "password_reset_confirm" // Password reset with a reset token
+	EventTypeVerificationRequest  = "verification_request"   // Verification email requested
+	EventTypeVerificationConfirm  = "verification_confirm"   // Email verified with a verification token
+	EventTypeEmailChangeRequest   = "email_change_request"   // Email change requested
+	EventTypeEmailChangeConfirm   = "email_change_confirm"   // Email changed with a change token
+	EventTypeOTPRequest           = "otp_request"            // One-time password requested
+	EventTypeMFAChallenge         = "mfa_challenge"          // First factor accepted, second factor required
+	EventTypeAuthRefresh          = "auth_refresh"           // Auth token refreshed
+	EventTypeOAuth2Link           = "oauth2_link"            // OAuth2 provider account linked
+	EventTypeOAuth2Unlink         = "oauth2_unlink"          // OAuth2 provider account unlinked
+
+	// Read Events (opt-in per collection)
+	EventTypeView = "view" // Single record returned via API
+	EventTypeList = "list" // Page of records returned via API
+
+	// File Events
+	EventTypeFileDownload = "file_download" // File (or thumb) downloaded via API
+
+	// Collection Schema Events (record_id is the collection ID)
+	EventTypeCollectionCreate = "collection_create" // Collection created
+	EventTypeCollectionUpdate = "collection_update" // Collection fields, indexes, rules or options changed
+	EventTypeCollectionDelete = "collection_delete" // Collection deleted
+
+	// Settings Events (collection_name is _params, record_id is settings)
+	EventTypeSettingsUpdate = "settings_update" // Application settings changed
+
+	// Backup Events (collection_name is _backups, record_id is the backup name)
+	EventTypeBackupCreate   = "backup_create"   // Backup created (API or automatic)
+	EventTypeBackupUpload   = "backup_upload"   // Backup uploaded via API
+	EventTypeBackupDownload = "backup_download" // Backup downloaded via API
+	EventTypeBackupDelete   = "backup_delete"   // Backup deleted via API
+	EventTypeBackupRestore  = "backup_restore"  // Backup restore started
+	EventTypeBackupRestored = "backup_restored" // First event after a restore (history discontinuity)
+
+	// Batch Events (collection_name is _batch)
+	EventTypeBatch = "batch" // Summary of an /api/batch request
+
+	// Custom Route Events (routes using the audit middleware)
+	EventTypeCustomRequest = "custom_request" // Request to a custom route
 )
 
 // AllEventTypes contains all supported event types for the audit log.
@@ -46,6 +89,33 @@ var AllEventTypes = []string{
 	EventTypeUpdate,
 	EventTypeDelete,
 	EventTypeAuth,
+	EventTypeAuthFailed,
+	EventTypePasswordResetRequest,
+	EventTypePasswordResetConfirm,
+	EventTypeVerificationRequest,
+	EventTypeVerificationConfirm,
+	EventTypeEmailChangeRequest,
+	EventTypeEmailChangeConfirm,
+	EventTypeOTPRequest,
+	EventTypeMFAChallenge,
+	EventTypeAuthRefresh,
+	EventTypeOAuth2Link,
+	EventTypeOAuth2Unlink,
+	EventTypeView,
+	EventTypeList,
+	EventTypeFileDownload,
+	EventTypeCollectionCreate,
+	EventTypeCollectionUpdate,
+	EventTypeCollectionDelete,
+	EventTypeSettingsUpdate,
+	EventTypeBackupCreate,
+	EventTypeBackupUpload,
+	EventTypeBackupDownload,
+	EventTypeBackupDelete,
+	EventTypeBackupRestore,
+	EventTypeBackupRestored,
+	EventTypeBatch,
+	EventTypeCustomRequest,
 }
 
 // AuditLogFields defines the field names used in the audit logs collection.
@@ -56,41 +126,74 @@ var AllEventTypes = []string{
 //   - collection_name: Name of the collection where operation occurred
 //   - record_id: ID of the affected record
 //   - user: Relation to users collection (who performed the action)
+//   - actor_collection: Auth collection of the actor (any auth collection, including _superusers)
+//   - actor_id: ID of the actor's auth record
+//   - actor_email: Email of the actor at the time of the event
 //   - auth_method: Authentication method used (for auth events)
 //   - request_method: HTTP method (GET, POST, PUT, DELETE, etc.)
 //   - request_ip: Client IP address (with reverse proxy support)
 //   - request_url: URL path of the request
+//   - request_id: ID of the HTTP request (links request and success events)
+//   - batch_id: ID of the /api/batch request an operation was part of
+//   - batch_index: Position of the operation in its batch (0-based)
 //   - timestamp: When the event occurred
+//   - expires_at: When retention may delete the record (empty = never by age)
 //   - before_changes: JSON snapshot of record before operation
 //   - after_changes: JSON snapshot of record after operation
+//   - changes: JSON diff of changed fields (update, collection_update and settings_update events only)
+//   - metadata: JSON with event-specific details (e.g. identity and reason of failed logins, IDs returned by list reads)
+//   - prev_hash: Hash of the previous audit record (hash chain)
+//   - hash: Hash of this record's fields plus prev_hash (hash chain)
 //   - created: Auto-generated creation timestamp
 //   - updated: Auto-generated update timestamp
 var AuditLogFields = struct {
-	EventType      string
-	CollectionName string
-	RecordID       string
-	User           string
-	AuthMethod     string
-	RequestMethod  string
-	RequestIP      string
-	RequestURL     string
-	Timestamp      string
-	BeforeChanges  string
-	AfterChanges   string
-	Created        string
-	Updated        string
+	EventType       string
+	CollectionName  string
+	RecordID        string
+	User            string
+	ActorCollection string
+	ActorID         string
+	ActorEmail      string
+	AuthMethod      string
+	RequestMethod   string
+	RequestIP       string
+	RequestURL      string
+	RequestID       string
+	BatchID         string
+	BatchIndex      string
+	Timestamp       string
+	ExpiresAt       string
+	BeforeChanges   string
+	AfterChanges    string
+	Changes         string
+	Metadata        string
+	PrevHash        string
+	Hash            string
+	Created         string
+	Updated         string
 }{
-	EventType:      "event_type",
-	CollectionName: "collection_name",
-	RecordID:       "record_id",
-	User:           "user",
-	AuthMethod:     "auth_method",
-	RequestMethod:  "request_method",
-	RequestIP:      "request_ip",
-	RequestURL:     "request_url",
-	Timestamp:      "timestamp",
-	BeforeChanges:  "before_changes",
-	AfterChanges:   "after_changes",
-	Created:        "created",
-	Updated:        "updated",
+	EventType:       "event_type",
+	CollectionName:  "collection_name",
+	RecordID:        "record_id",
+	User:            "user",
+	ActorCollection: "actor_collection",
+	ActorID:         "actor_id",
+	ActorEmail:      "actor_email",
+	AuthMethod:      "auth_method",
+	RequestMethod:   "request_method",
+	RequestIP:       "request_ip",
+	RequestURL:      "request_url",
+	RequestID:       "request_id",
+	BatchID:         "batch_id",
+	BatchIndex:      "batch_index",
+	Timestamp:       "timestamp",
+	ExpiresAt:       "expires_at",
+	BeforeChanges:   "before_changes",
+	AfterChanges:    "after_changes",
+	Changes:         "changes",
+	Metadata:        "metadata",
+	PrevHash:        "prev_hash",
+	Hash:            "hash",
+	Created:         "created",
+	Updated:         "updated",
 }
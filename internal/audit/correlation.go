@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+const (
+	// requestIDHeader is the header used to pass a request ID from upstream
+	// proxies/clients. It is also echoed back on the response.
+	requestIDHeader = "X-Request-Id"
+
+	// requestIDStoreKey is the request event store key holding the request ID,
+	// so every hook of the same HTTP request uses the same ID.
+	requestIDStoreKey = "pbAuditRequestId"
+
+	// requestIDMaxLength caps incoming request IDs to the audit field size.
+	requestIDMaxLength = 100
+
+	// pendingRequestTTL is how long request context is kept for a record whose
+	// success (or error) hook never fired, e.g. because a later hook aborted.
+	pendingRequestTTL = 10 * time.Minute
+)
+
+// requestContext is the request data carried from a request hook to the
+// success hook of the same record or collection operation.
+type requestContext struct {
+	requestID string
+	actor     *core.Record // Authenticated record of the request (nil = guest)
+	createdAt time.Time
+
+	// Batch sub-requests only
+	batchID    string
+	batchIndex int
+}
+
+// correlator links request events to the success events they cause.
+//
+// Request hooks and success hooks receive the same *core.Record (or
+// *core.Collection) instance for a single operation, so the pointer is used
+// as the key. Entries are removed when the success or error hook fires, and
+// stale entries are swept periodically.
+type correlator struct {
+	pending   sync.Map
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// remember stores the request context for a record or collection that is
+// about to be saved.
+func (c *correlator) remember(model any, ctx requestContext) {
+	ctx.createdAt = time.Now()
+	c.pending.Store(model, ctx)
+	c.sweep()
+}
+
+// take returns and forgets the request context of a record or collection.
+func (c *correlator) take(model any) (requestContext, bool) {
+	value, ok := c.pending.LoadAndDelete(model)
+	if !ok {
+		return requestContext{}, false
+	}
+	return value.(requestContext), true
+}
+
+// forget discards the request context of a record or collection (failed operations).
+func (c *correlator) forget(model any) {
+	c.pending.Delete(model)
+}
+
+// sweep removes stale entries at most once per minute.
+func (c *correlator) sweep() {
+	c.mu.Lock()
+	if time.Since(c.lastSweep) < time.Minute {
+		c.mu.Unlock()
+		return
+	}
+	c.lastSweep = time.Now()
+	c.mu.Unlock()
+
+	c.pending.Range(func(key, value any) bool {
+		if time.Since(value.(requestContext).createdAt) > pendingRequestTTL {
+			c.pending.Delete(key)
+		}
+		return true
+	})
+}
+
+// registerCorrelationHooks links API requests to their success events.
+//
+// The request hooks store the request ID and actor for the record being saved
+// and the success hooks pick them up (see logger.requestInfoFor). Failed operations
+// discard it.
+func registerCorrelationHooks(app *pocketbase.PocketBase, logger *logger) error {
+	remember := func(e *core.RecordRequestEvent) error {
+		if e.Collection.Name != logger.options.CollectionName {
+			ctx := requestContext{
+				requestID: requestID(e.RequestEvent),
+				actor:     e.Auth,
+			}
+			ctx.batchID, ctx.batchIndex, _ = batchInfo(e.RequestEvent)
+			logger.correlator.remember(e.Record, ctx)
+		}
+		return e.Next()
+	}
+
+	app.OnRecordCreateRequest().BindFunc(remember)
+	app.OnRecordUpdateRequest().BindFunc(remember)
+	app.OnRecordDeleteRequest().BindFunc(remember)
+
+	forget := func(e *core.RecordErrorEvent) error {
+		logger.correlator.forget(e.Record)
+		return e.Next()
+	}
+
+	app.OnRecordAfterCreateError().BindFunc(forget)
+	app.OnRecordAfterUpdateError().BindFunc(forget)
+	app.OnRecordAfterDeleteError().BindFunc(forget)
+
+	return nil
+}
+
+// requestID returns the ID of the current HTTP request.
+//
+// The ID is taken from the X-Request-Id header when present and made of
+// printable ASCII (longer IDs are cut to requestIDMaxLength), otherwise a new
+// random ID is generated. It is cached on the request event so all audit events
+// of the same HTTP request share it, and echoed back in the response header.
+func requestID(e *core.RequestEvent) string {
+	if id, ok := e.Get(requestIDStoreKey).(string); ok && id != "" {
+		return id
+	}
+
+	id := strings.TrimSpace(e.Request.Header.Get(requestIDHeader))
+	if !isPrintableASCII(id) {
+		id = ""
+	}
+	// Byte offsets are character offsets in ASCII
+	if len(id) > requestIDMaxLength {
+		id = id[:requestIDMaxLength]
+	}
+	if id == "" {
+		id = security.RandomString(20)
+	}
+
+	e.Set(requestIDStoreKey, id)
+
+	if e.Response.Header().Get(requestIDHeader) == "" {
+		e.Response.Header().Set(requestIDHeader, id)
+	}
+
+	return id
+}
+
+// isPrintableASCII reports whether s only contains printable US-ASCII
+// characters other than space (33-126).
+func isPrintableASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '!' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
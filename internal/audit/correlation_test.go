@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestRequestID(t *testing.T) {
+	long := strings.Repeat("a", requestIDMaxLength+10)
+
+	scenarios := []struct {
+		name     string
+		header   string
+		expected string // empty = a generated ID
+	}{
+		{"no header", "", ""},
+		{"plain ID", "req-123", "req-123"},
+		{"surrounding spaces", "  req-123 ", "req-123"},
+		{"too long", long, long[:requestIDMaxLength]},
+		{"multi-byte characters", strings.Repeat("a", requestIDMaxLength-1) + "é", ""},
+		{"inner space", "req 123", ""},
+		{"control character", "req\x00123", ""},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set(requestIDHeader, s.header)
+			response := httptest.NewRecorder()
+
+			e := &core.RequestEvent{}
+			e.Request = request
+			e.Response = response
+
+			id := requestID(e)
+
+			if s.expected != "" && id != s.expected {
+				t.Fatalf("expected request ID %q, got %q", s.expected, id)
+			}
+			if s.expected == "" && (len(id) != 20 || id == s.header) {
+				t.Fatalf("expected a generated request ID, got %q", id)
+			}
+			if header := response.Header().Get(requestIDHeader); header != id {
+				t.Fatalf("expected the response header %q, got %q", id, header)
+			}
+			if again := requestID(e); again != id {
+				t.Fatalf("expected the cached request ID %q, got %q", id, again)
+			}
+		})
+	}
+}
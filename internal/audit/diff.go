@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// FieldChange describes how a single field changed between two record states.
+//
+// Old and New always hold the complete values. Multi-value fields (relations,
+// selects, files, JSON arrays) also list the individual items that were added
+// or removed, and JSON objects carry a nested diff keyed by property name.
+type FieldChange struct {
+	Old     any                    `json:"old"`
+	New     any                    `json:"new"`
+	Added   []any                  `json:"added,omitempty"`
+	Removed []any                  `json:"removed,omitempty"`
+	Changes map[string]FieldChange `json:"changes,omitempty"`
+}
+
+// computeChanges compares two record snapshots and returns the changed fields.
+//
+// Both snapshots are the decoded JSON form of a record, so relation and select
+// fields appear as arrays and JSON fields as nested objects. Fields that are
+// equal on both sides are left out of the result.
+//
+// PARAMETERS:
+//   - before: Record snapshot before the operation
+//   - after: Record snapshot after the operation
+//
+// RETURNS:
+//   - Map of field name to change (empty if nothing changed)
+func computeChanges(before, after map[string]any) map[string]FieldChange {
+	changes := make(map[string]FieldChange)
+
+	for key, oldValue := range before {
+		if change, changed := diffValues(oldValue, after[key]); changed {
+			changes[key] = change
+		}
+	}
+
+	// Fields that only exist in the after state
+	for key, newValue := range after {
+		if _, ok := before[key]; ok {
+			continue
+		}
+		if change, changed := diffValues(nil, newValue); changed {
+			changes[key] = change
+		}
+	}
+
+	return changes
+}
+
+// computeRecordChanges compares two snapshots of a record of the given
+// collection. Autodate fields (e.g. updated) are left out, since they change
+// on every save and would add noise to every diff.
+func computeRecordChanges(collection *core.Collection, before, after map[string]any) map[string]FieldChange {
+	var autodate []string
+	for _, field := range collection.Fields {
+		if field.Type() == core.FieldTypeAutodate {
+			autodate = append(autodate, field.GetName())
+		}
+	}
+
+	return computeChanges(withoutKeys(before, autodate...), withoutKeys(after, autodate...))
+}
+
+// diffValues compares two decoded JSON values.
+// Returns false if the values are equal.
+func diffValues(oldValue, newValue any) (FieldChange, bool) {
+	if reflect.DeepEqual(oldValue, newValue) {
+		return FieldChange{}, false
+	}
+
+	change := FieldChange{Old: oldValue, New: newValue}
+
+	// Nested JSON objects get a structured diff
+	oldMap, oldIsMap := oldValue.(map[string]any)
+	newMap, newIsMap := newValue.(map[string]any)
+	if oldIsMap && newIsMap {
+		change.Changes = computeChanges(oldMap, newMap)
+		return change, true
+	}
+
+	// Multi-value fields get the added and removed items
+	oldList, oldIsList := asList(oldValue)
+	newList, newIsList := asList(newValue)
+	if oldIsList && newIsList {
+		change.Added = subtractList(newList, oldList)
+		change.Removed = subtractList(oldList, newList)
+	}
+
+	return change, true
+}
+
+// asList converts a decoded JSON array to a slice.
+// nil is treated as an empty list so that a first value counts as "added".
+func asList(value any) ([]any, bool) {
+	if value == nil {
+		return nil, true
+	}
+	list, ok := value.([]any)
+	return list, ok
+}
+
+// subtractList returns the items of a that are not in b.
+// Duplicates are respected, so [x, x] minus [x] leaves [x].
+func subtractList(a, b []any) []any {
+	remaining := make(map[string]int, len(b))
+	for _, item := range b {
+		remaining[listKey(item)]++
+	}
+
+	var result []any
+	for _, item := range a {
+		key := listKey(item)
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		result = append(result, item)
+	}
+
+	return result
+}
+
+// listKey returns a comparable key for an item of a decoded JSON array.
+func listKey(item any) string {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
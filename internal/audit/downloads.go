@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// registerFileDownloadHooks registers hooks for file downloads.
+//
+// Downloads of protected file fields are always logged; downloads of public
+// files are only logged for the collections in ReadCollections. The hook
+// runs after PocketBase checked the file token of protected files and only
+// logs once the file was served.
+//
+// The event is stored on the record's collection with the record as
+// record_id and the owner of the file token (or the authenticated request)
+// as actor. The field name, the requested filename, the thumb size (if
+// any) and the served filename go into the metadata.
+func registerFileDownloadHooks(app *pocketbase.PocketBase, logger *logger) error {
+	app.OnFileDownloadRequest().BindFunc(func(e *core.FileDownloadRequestEvent) error {
+		if e.Record == nil || e.FileField == nil {
+			return e.Next()
+		}
+
+		if !e.FileField.Protected && !logger.logsReadsFor(e.Collection.Name) {
+			return e.Next()
+		}
+
+		err := e.Next()
+		if err != nil {
+			return err
+		}
+
+		metadata := map[string]any{
+			"field":       e.FileField.Name,
+			"filename":    e.Request.PathValue("filename"),
+			"served_name": e.ServedName,
+			"protected":   e.FileField.Protected,
+		}
+		if thumb := e.Request.URL.Query().Get("thumb"); thumb != "" {
+			metadata["thumb"] = thumb
+		}
+
+		requestInfo := extractRequestInfo(e.RequestEvent)
+		requestInfo[AuditLogFields.RecordID] = e.Record.Id
+		requestInfo[AuditLogFields.Metadata] = metadata
+
+		applyFileTokenActor(logger.app, requestInfo, e.RequestEvent)
+
+		if err := logger.logEvent(nil, nil, e.Collection.Name, EventTypeFileDownload, requestInfo); err != nil {
+			appLogger(logger.app).Warn("Failed to log file download",
+				"collection", e.Collection.Name, "record", e.Record.Id, "field", e.FileField.Name, "error", err)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+// applyFileTokenActor adds the owner of the request's file token as actor,
+// unless the request already has an authenticated actor.
+//
+// Protected files and backups are downloaded with a file token in the
+// "token" query parameter instead of the Authorization header.
+func applyFileTokenActor(app core.App, requestInfo map[string]interface{}, e *core.RequestEvent) {
+	if _, ok := requestInfo[AuditLogFields.ActorID]; ok {
+		return
+	}
+
+	token := e.Request.URL.Query().Get("token")
+	if token == "" {
+		return
+	}
+
+	if actor, err := app.FindAuthRecordByToken(token, core.TokenTypeFile); err == nil {
+		applyActor(requestInfo, actor)
+	}
+}
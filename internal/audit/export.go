@@ -0,0 +1,369 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+	"github.com/spf13/cobra"
+)
+
+// Export formats supported by the export command.
+const (
+	exportFormatJSONL = "jsonl"
+	exportFormatCSV   = "csv"
+)
+
+// exportFilter holds the filters of an audit export.
+type exportFilter struct {
+	From            string   // Only events at or after this time
+	To              string   // Only events before this time
+	Collections     []string // Only events on these collections
+	EventTypes      []string // Only these event types
+	User            string   // Only events by this user ID (users collection)
+	ActorID         string   // Only events by this actor ID (any auth collection)
+	ActorCollection string   // Only events by actors of this auth collection
+	RecordID        string   // Only events on this record ID
+}
+
+// NewCommand creates the "audit" CLI command with its subcommands.
+//
+// USAGE:
+//
+//	./app audit export --from 2024-01-01 --to 2024-04-01 --format csv -o q1.csv
+//
+// The command runs after the app is bootstrapped, so the audit collection
+// already exists when it executes.
+func NewCommand(app *pocketbase.PocketBase, options Options) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "audit",
+		Short: "Audit log utilities",
+	}
+
+	command.AddCommand(newExportCommand(app, options))
+
+	return command
+}
+
+// newExportCommand creates the "audit export" command.
+func newExportCommand(app *pocketbase.PocketBase, options Options) *cobra.Command {
+	var (
+		filter exportFilter
+		from   string
+		to     string
+		format string
+		output string
+	)
+
+	command := &cobra.Command{
+		Use:   "export",
+		Short: "Export audit records as JSONL or CSV",
+		Long: "Streams audit records, oldest first, to stdout or a file.\n" +
+			"Times accept RFC3339, \"2006-01-02 15:04:05\" or \"2006-01-02\" (UTC).",
+		Example: "  audit export --from 2024-01-01 --to 2024-04-01 --format csv -o q1.csv\n" +
+			"  audit export --collection payments --event-type delete --event-type delete_request\n" +
+			"  audit export --actor-collection _superusers --format csv -o admins.csv",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if filter.From, err = parseExportTime(from); err != nil {
+				return fmt.Errorf("invalid --from: %w", err)
+			}
+			if filter.To, err = parseExportTime(to); err != nil {
+				return fmt.Errorf("invalid --to: %w", err)
+			}
+
+			if format != exportFormatJSONL && format != exportFormatCSV {
+				return fmt.Errorf("unknown format %q (expected %s or %s)", format, exportFormatJSONL, exportFormatCSV)
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				defer file.Close()
+				w = file
+			}
+
+			count, err := exportRecords(app, options.CollectionName, filter, format, w)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d audit records\n", count)
+			return nil
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&format, "format", exportFormatJSONL, "output format (jsonl or csv)")
+	flags.StringVarP(&output, "output", "o", "", "output file (default stdout)")
+	flags.StringVar(&from, "from", "", "only events at or after this time")
+	flags.StringVar(&to, "to", "", "only events before this time")
+	flags.StringSliceVar(&filter.Collections, "collection", nil, "only events on this collection (repeatable)")
+	flags.StringSliceVar(&filter.EventTypes, "event-type", nil, "only events of this type (repeatable)")
+	flags.StringVar(&filter.User, "user", "", "only events by this user ID (users collection)")
+	flags.StringVar(&filter.ActorID, "actor", "", "only events by this actor ID (any auth collection)")
+	flags.StringVar(&filter.ActorCollection, "actor-collection", "", "only events by actors of this auth collection")
+	flags.StringVar(&filter.RecordID, "record", "", "only events on this record ID")
+
+	return command
+}
+
+// exportRecords streams the audit records matching the filter to w.
+//
+// Records are read with a single cursor ordered by timestamp, so memory usage
+// stays constant regardless of the number of records exported.
+//
+// RETURNS:
+//   - Number of records written
+//   - error if the query or a write fails
+func exportRecords(app core.App, collectionName string, filter exportFilter, format string, w io.Writer) (int, error) {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return 0, fmt.Errorf("audit logs collection not found: %w", err)
+	}
+
+	query := app.DB().
+		Select(collection.Name+".*").
+		From(collection.Name).
+		OrderBy(AuditLogFields.Timestamp+" ASC", "rowid ASC")
+
+	for _, exp := range filter.expressions() {
+		query.AndWhere(exp)
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query audit records: %w", err)
+	}
+	defer rows.Close()
+
+	var encoder recordEncoder
+	if format == exportFormatCSV {
+		encoder = newCSVEncoder(w, collection)
+	} else {
+		encoder = newJSONLEncoder(w)
+	}
+
+	count := 0
+	for rows.Next() {
+		data := dbx.NullStringMap{}
+		if err := rows.ScanMap(data); err != nil {
+			return count, fmt.Errorf("failed to read audit record: %w", err)
+		}
+
+		record, err := newRecordFromRow(collection, data)
+		if err != nil {
+			return count, fmt.Errorf("failed to read audit record: %w", err)
+		}
+		if err := encoder.encode(record); err != nil {
+			return count, fmt.Errorf("failed to write audit record: %w", err)
+		}
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to read audit records: %w", err)
+	}
+
+	return count, encoder.flush()
+}
+
+// newRecordFromRow loads a scanned row of the audit logs collection into a
+// record, normalizing the raw column values with the collection fields.
+func newRecordFromRow(collection *core.Collection, data dbx.NullStringMap) (*core.Record, error) {
+	record := core.NewRecord(collection)
+
+	for _, field := range collection.Fields {
+		var raw any
+		if nullString, ok := data[field.GetName()]; ok && nullString.Valid {
+			raw = nullString.String
+		}
+
+		value, err := field.PrepareValue(record, raw)
+		if err != nil {
+			return nil, err
+		}
+		record.SetRaw(field.GetName(), value)
+	}
+
+	record.Id = record.GetString(core.FieldNameId)
+	record.MarkAsNotNew()
+
+	return record, nil
+}
+
+// expressions converts the filter to query expressions.
+func (f exportFilter) expressions() []dbx.Expression {
+	var exps []dbx.Expression
+
+	if f.From != "" {
+		exps = append(exps, dbx.NewExp(AuditLogFields.Timestamp+" >= {:from}", dbx.Params{"from": f.From}))
+	}
+	if f.To != "" {
+		exps = append(exps, dbx.NewExp(AuditLogFields.Timestamp+" < {:to}", dbx.Params{"to": f.To}))
+	}
+	if len(f.Collections) > 0 {
+		exps = append(exps, dbx.In(AuditLogFields.CollectionName, toAnySlice(f.Collections)...))
+	}
+	if len(f.EventTypes) > 0 {
+		exps = append(exps, dbx.In(AuditLogFields.EventType, toAnySlice(f.EventTypes)...))
+	}
+	if f.User != "" {
+		exps = append(exps, dbx.HashExp{AuditLogFields.User: f.User})
+	}
+	if f.ActorID != "" {
+		exps = append(exps, dbx.HashExp{AuditLogFields.ActorID: f.ActorID})
+	}
+	if f.ActorCollection != "" {
+		exps = append(exps, dbx.HashExp{AuditLogFields.ActorCollection: f.ActorCollection})
+	}
+	if f.RecordID != "" {
+		exps = append(exps, dbx.HashExp{AuditLogFields.RecordID: f.RecordID})
+	}
+
+	return exps
+}
+
+// parseExportTime parses a CLI time argument to the stored date format.
+func parseExportTime(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	layouts := []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			dt, err := types.ParseDateTime(t)
+			if err != nil {
+				return "", err
+			}
+			return dt.String(), nil
+		}
+	}
+
+	return "", errors.New("expected RFC3339, \"2006-01-02 15:04:05\" or \"2006-01-02\"")
+}
+
+// toAnySlice converts a string slice for use with dbx.In.
+func toAnySlice(values []string) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
+
+// recordEncoder writes audit records in an export format.
+type recordEncoder interface {
+	encode(record *core.Record) error
+	flush() error
+}
+
+// jsonlEncoder writes one JSON object per line.
+type jsonlEncoder struct {
+	encoder *json.Encoder
+}
+
+func newJSONLEncoder(w io.Writer) *jsonlEncoder {
+	return &jsonlEncoder{encoder: json.NewEncoder(w)}
+}
+
+func (e *jsonlEncoder) encode(record *core.Record) error {
+	return e.encoder.Encode(record.FieldsData())
+}
+
+func (e *jsonlEncoder) flush() error {
+	return nil
+}
+
+// csvEncoder writes a header row with the collection fields, then one row per record.
+// JSON fields are written as raw JSON strings. Cells are neutralized against
+// formula injection (see csvSafe), since record data and client-controlled
+// headers end up in files opened in spreadsheets.
+type csvEncoder struct {
+	writer        *csv.Writer
+	fields        []string
+	headerWritten bool
+}
+
+func newCSVEncoder(w io.Writer, collection *core.Collection) *csvEncoder {
+	return &csvEncoder{
+		writer: csv.NewWriter(w),
+		fields: collection.Fields.FieldNames(),
+	}
+}
+
+func (e *csvEncoder) encode(record *core.Record) error {
+	if !e.headerWritten {
+		if err := e.writer.Write(e.fields); err != nil {
+			return err
+		}
+		e.headerWritten = true
+	}
+
+	row := make([]string, len(e.fields))
+	for i, name := range e.fields {
+		row[i] = csvSafe(csvValue(record.Get(name)))
+	}
+
+	return e.writer.Write(row)
+}
+
+func (e *csvEncoder) flush() error {
+	// Write the header even if there were no records
+	if !e.headerWritten {
+		if err := e.writer.Write(e.fields); err != nil {
+			return err
+		}
+	}
+
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// csvValue converts a record field value to a CSV cell.
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case types.DateTime:
+		return v.String()
+	case types.JSONRaw:
+		return string(v)
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// csvSafe neutralizes a CSV cell that a spreadsheet would evaluate as a
+// formula (starting with =, +, -, @, tab or carriage return) by prefixing it
+// with a single quote.
+func csvSafe(cell string) string {
+	if cell == "" {
+		return cell
+	}
+
+	switch cell[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + cell
+	default:
+		return cell
+	}
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestExportRecords(t *testing.T) {
+	options := testOptions()
+	options.LogSchemaEvents = false
+	app := newTestApp(t, options)
+
+	collection, err := app.FindCollectionByNameOrId("audit_logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []struct {
+		actorCollection string
+		actorID         string
+		requestURL      string
+	}{
+		{"_superusers", "admin1", "/api/settings"},
+		{"staff", "staff1", "=HYPERLINK(\"http://evil\")"},
+		{"users", "user1", "/api/collections/notes/records"},
+	}
+	for _, event := range events {
+		record := core.NewRecord(collection)
+		record.Set(AuditLogFields.EventType, EventTypeUpdateRequest)
+		record.Set(AuditLogFields.CollectionName, "notes")
+		record.Set(AuditLogFields.Timestamp, types.NowDateTime())
+		record.Set(AuditLogFields.ActorCollection, event.actorCollection)
+		record.Set(AuditLogFields.ActorID, event.actorID)
+		record.Set(AuditLogFields.RequestURL, event.requestURL)
+		if err := app.Save(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scenarios := []struct {
+		name           string
+		filter         exportFilter
+		expectedActors []string
+	}{
+		{"no filter", exportFilter{}, []string{"admin1", "staff1", "user1"}},
+		{"actor of a non-users collection", exportFilter{ActorID: "staff1"}, []string{"staff1"}},
+		{"actor collection", exportFilter{ActorCollection: "_superusers"}, []string{"admin1"}},
+		{"actor and collection mismatch", exportFilter{ActorID: "admin1", ActorCollection: "staff"}, nil},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			count, err := exportRecords(app, "audit_logs", s.filter, exportFormatCSV, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != len(s.expectedActors) {
+				t.Fatalf("expected %d records, got %d", len(s.expectedActors), count)
+			}
+
+			rows, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			header := rows[0]
+			column := func(name string) int {
+				for i, field := range header {
+					if field == name {
+						return i
+					}
+				}
+				t.Fatalf("missing column %s", name)
+				return -1
+			}
+
+			for i, row := range rows[1:] {
+				if actor := row[column(AuditLogFields.ActorID)]; actor != s.expectedActors[i] {
+					t.Errorf("row %d: expected actor %s, got %s", i, s.expectedActors[i], actor)
+				}
+				if url := row[column(AuditLogFields.RequestURL)]; strings.HasPrefix(url, "=") {
+					t.Errorf("row %d: expected the formula to be neutralized, got %s", i, url)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	scenarios := []struct {
+		cell     string
+		expected string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"a=b", "a=b"},
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\rcmd", "'\rcmd"},
+	}
+
+	for _, s := range scenarios {
+		if result := csvSafe(s.cell); result != s.expected {
+			t.Errorf("csvSafe(%q): expected %q, got %q", s.cell, s.expected, result)
+		}
+	}
+}
@@ -1,10 +1,25 @@
 package audit
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+const (
+	// otpAuthRouteSuffix is the path suffix of the PocketBase auth-with-otp route.
+	otpAuthRouteSuffix = "/auth-with-otp"
+
+	// otpAuthLoggedStoreKey marks an auth-with-otp request whose failure was
+	// already logged by the OTP request hook.
+	otpAuthLoggedStoreKey = "pbAuditOTPAuthLogged"
 )
 
 // registerHooks sets up all audit logging hooks.
@@ -12,7 +27,12 @@ import (
 // HOOK TYPES:
 // 1. Request hooks - Capture API operations before commit with full context
 // 2. Success hooks - Confirm database operations after successful commit
-// 3. Auth hooks - Track authentication events
+// 3. Auth hooks - Track authentication events (successful and failed) and the account lifecycle
+// 4. Read hooks - Track view and list requests of the collections in ReadCollections
+// 5. File download hooks - Track downloads of protected files
+// 6. Collection hooks - Track collection schema changes (fields, indexes, API rules)
+// 7. Settings hooks - Track application settings changes (secrets masked)
+// 8. Backup hooks - Track backup operations and mark restores after the restart
 //
 // The dual-tracking system (request + success) provides complete audit trail:
 // - Request events show user intent with IP, method, and before state
@@ -26,25 +46,64 @@ import (
 //   - nil on successful hook registration
 //   - error if registration fails
 func registerHooks(app *pocketbase.PocketBase, options Options) error {
+	// A synchronous stream syslog sink would wait for the server (up to its
+	// timeout per event) inside every request
+	if options.Async == nil {
+		for _, sink := range options.Sinks {
+			if syslog, ok := sink.(*syslogSink); ok && syslog.isStream() {
+				return fmt.Errorf("sink %s uses %s and requires Async", syslog.Name(), syslog.options.Network)
+			}
+		}
+	}
+
 	logger := newLogger(app, options)
 
+	// Make the logger available to the custom route middleware
+	app.Store().Set(loggerStoreKey, logger)
+
+	// Start the background writer if async mode is enabled
+	if options.Async != nil {
+		logger.writer = newAsyncWriter(app, options, logger.sinks)
+		logger.writer.start()
+		if options.LogToConsole {
+			appLogger(app).Debug("Async audit writer started")
+		}
+	}
+
+	// Close the sinks on shutdown (after the async writer has drained its queue)
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		logger.sinks.close()
+		return e.Next()
+	})
+
+	// Register batch hooks first, so batch sub-requests are numbered before
+	// the request and correlation hooks log them
+	if options.LogRequestEvents || options.LogSuccessEvents {
+		if err := registerBatchHooks(app, logger); err != nil {
+			return err
+		}
+	}
+
 	// Register request hooks (API operations before commit)
 	if options.LogRequestEvents {
 		if err := registerRequestHooks(app, logger); err != nil {
 			return err
 		}
 		if options.LogToConsole {
-			fmt.Println("✅ SUCCESS Request event hooks registered")
+			appLogger(app).Debug("Request event hooks registered")
 		}
 	}
 
 	// Register success hooks (database operations after commit)
 	if options.LogSuccessEvents {
+		if err := registerCorrelationHooks(app, logger); err != nil {
+			return err
+		}
 		if err := registerSuccessHooks(app, logger); err != nil {
 			return err
 		}
 		if options.LogToConsole {
-			fmt.Println("✅ SUCCESS Success event hooks registered")
+			appLogger(app).Debug("Success event hooks registered")
 		}
 	}
 
@@ -53,8 +112,64 @@ func registerHooks(app *pocketbase.PocketBase, options Options) error {
 		if err := registerAuthHooks(app, logger); err != nil {
 			return err
 		}
+		if err := registerAuthFailureHooks(app, logger); err != nil {
+			return err
+		}
+		if err := registerAuthLifecycleHooks(app, logger); err != nil {
+			return err
+		}
+		if options.LogToConsole {
+			appLogger(app).Debug("Auth event hooks registered")
+		}
+	}
+
+	// Register read hooks (view and list requests, opt-in per collection)
+	if len(options.ReadCollections) > 0 {
+		if err := registerReadHooks(app, logger); err != nil {
+			return err
+		}
+		if options.LogToConsole {
+			appLogger(app).Debug("Read event hooks registered", "collections", options.ReadCollections)
+		}
+	}
+
+	// Register file download hooks (protected files and read-audited collections)
+	if options.LogFileDownloads {
+		if err := registerFileDownloadHooks(app, logger); err != nil {
+			return err
+		}
 		if options.LogToConsole {
-			fmt.Println("✅ SUCCESS Auth event hooks registered")
+			appLogger(app).Debug("File download hooks registered")
+		}
+	}
+
+	// Register collection hooks (schema changes, including the audit collection)
+	if options.LogSchemaEvents {
+		if err := registerCollectionHooks(app, logger); err != nil {
+			return err
+		}
+		if options.LogToConsole {
+			appLogger(app).Debug("Collection schema hooks registered")
+		}
+	}
+
+	// Register settings hooks (settings updates through the API)
+	if options.LogSettingsEvents {
+		if err := registerSettingsHooks(app, logger); err != nil {
+			return err
+		}
+		if options.LogToConsole {
+			appLogger(app).Debug("Settings hooks registered")
+		}
+	}
+
+	// Register backup hooks (backup API and backup/restore operations)
+	if options.LogBackupEvents {
+		if err := registerBackupHooks(app, logger); err != nil {
+			return err
+		}
+		if options.LogToConsole {
+			appLogger(app).Debug("Backup hooks registered")
 		}
 	}
 
@@ -80,13 +195,12 @@ func registerRequestHooks(app *pocketbase.PocketBase, logger *logger) error {
 		}
 
 		// Extract request information
-		requestInfo := extractRequestInfo(e)
+		requestInfo := extractRequestInfo(e.RequestEvent)
 
 		// For create requests, there's no before state
 		if err := logger.logEvent(e.Record, nil, e.Collection.Name, EventTypeCreateRequest, requestInfo); err != nil {
-			if logger.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to log create request: %v\n", err)
-			}
+			appLogger(logger.app).Warn("Failed to log create request",
+				"collection", e.Collection.Name, "record", e.Record.Id, "error", err)
 		}
 
 		return e.Next()
@@ -102,20 +216,18 @@ func registerRequestHooks(app *pocketbase.PocketBase, logger *logger) error {
 		// Load original record to get before state
 		originalRecord, err := logger.app.FindRecordById(e.Collection.Name, e.Record.Id)
 		if err != nil {
-			if logger.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to load original record for update: %v\n", err)
-			}
+			appLogger(logger.app).Warn("Failed to load original record for update",
+				"collection", e.Collection.Name, "record", e.Record.Id, "error", err)
 			originalRecord = nil
 		}
 
 		// Extract request information
-		requestInfo := extractRequestInfo(e)
+		requestInfo := extractRequestInfo(e.RequestEvent)
 
 		// Log with both before and after states
 		if err := logger.logEvent(e.Record, originalRecord, e.Collection.Name, EventTypeUpdateRequest, requestInfo); err != nil {
-			if logger.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to log update request: %v\n", err)
-			}
+			appLogger(logger.app).Warn("Failed to log update request",
+				"collection", e.Collection.Name, "record", e.Record.Id, "error", err)
 		}
 
 		return e.Next()
@@ -129,13 +241,12 @@ func registerRequestHooks(app *pocketbase.PocketBase, logger *logger) error {
 		}
 
 		// Extract request information
-		requestInfo := extractRequestInfo(e)
+		requestInfo := extractRequestInfo(e.RequestEvent)
 
 		// For delete requests, record is the before state, no after state
 		if err := logger.logEvent(nil, e.Record, e.Collection.Name, EventTypeDeleteRequest, requestInfo); err != nil {
-			if logger.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to log delete request: %v\n", err)
-			}
+			appLogger(logger.app).Warn("Failed to log delete request",
+				"collection", e.Collection.Name, "record", e.Record.Id, "error", err)
 		}
 
 		return e.Next()
@@ -149,10 +260,12 @@ func registerRequestHooks(app *pocketbase.PocketBase, logger *logger) error {
 // These hooks capture:
 // - All database operations (API and programmatic)
 // - Final state after all validations and hooks
+// - Original state for updates (snapshot taken right before the update executes)
 // - Confirmation that operation committed successfully
 //
 // Success events fire AFTER the database commit, guaranteeing the operation
-// completed. However, they have limited access to request metadata.
+// completed. However, they have limited access to request metadata: only the
+// request ID is carried over from API requests (see registerCorrelationHooks).
 func registerSuccessHooks(app *pocketbase.PocketBase, logger *logger) error {
 	// Hook: Create Success
 	app.OnRecordAfterCreateSuccess().BindFunc(func(e *core.RecordEvent) error {
@@ -164,15 +277,35 @@ func registerSuccessHooks(app *pocketbase.PocketBase, logger *logger) error {
 		}
 
 		// For create events, there's no before state
-		if err := logger.logEvent(e.Record, nil, collectionName, EventTypeCreate, nil); err != nil {
-			if logger.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to log create success: %v\n", err)
-			}
+		if err := logger.logEvent(e.Record, nil, collectionName, EventTypeCreate, logger.requestInfoFor(e.Record)); err != nil {
+			appLogger(logger.app).Warn("Failed to log create success",
+				"collection", collectionName, "record", e.Record.Id, "error", err)
 		}
 
 		return e.Next()
 	})
 
+	// Hook: Update (before execution) - remember the original state
+	app.OnRecordUpdate().BindFunc(func(e *core.RecordEvent) error {
+		// Skip audit logs collection to prevent recursion
+		if e.Record.Collection().Name == logger.options.CollectionName {
+			return e.Next()
+		}
+
+		if err := rememberOriginal(e); err != nil {
+			appLogger(logger.app).Warn("Failed to load original record for update",
+				"collection", e.Record.Collection().Name, "record", e.Record.Id, "error", err)
+		}
+
+		if err := e.Next(); err != nil {
+			return err
+		}
+
+		rememberSaved(e)
+
+		return nil
+	})
+
 	// Hook: Update Success
 	app.OnRecordAfterUpdateSuccess().BindFunc(func(e *core.RecordEvent) error {
 		collectionName := e.Record.Collection().Name
@@ -182,12 +315,12 @@ func registerSuccessHooks(app *pocketbase.PocketBase, logger *logger) error {
 			return e.Next()
 		}
 
-		// For update success events, we don't have easy access to before state
-		// The request hook already captured it, this confirms the commit
-		if err := logger.logEvent(e.Record, nil, collectionName, EventTypeUpdate, nil); err != nil {
-			if logger.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to log update success: %v\n", err)
-			}
+		// Use the states captured when the update was executed
+		originalRecord, savedRecord := takeOriginal(e)
+
+		if err := logger.logEvent(savedRecord, originalRecord, collectionName, EventTypeUpdate, logger.requestInfoFor(e.Record)); err != nil {
+			appLogger(logger.app).Warn("Failed to log update success",
+				"collection", collectionName, "record", e.Record.Id, "error", err)
 		}
 
 		return e.Next()
@@ -203,10 +336,9 @@ func registerSuccessHooks(app *pocketbase.PocketBase, logger *logger) error {
 		}
 
 		// For delete events, record is the before state, no after state
-		if err := logger.logEvent(nil, e.Record, collectionName, EventTypeDelete, nil); err != nil {
-			if logger.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to log delete success: %v\n", err)
-			}
+		if err := logger.logEvent(nil, e.Record, collectionName, EventTypeDelete, logger.requestInfoFor(e.Record)); err != nil {
+			appLogger(logger.app).Warn("Failed to log delete success",
+				"collection", collectionName, "record", e.Record.Id, "error", err)
 		}
 
 		return e.Next()
@@ -218,22 +350,25 @@ func registerSuccessHooks(app *pocketbase.PocketBase, logger *logger) error {
 // registerAuthHooks registers hooks for authentication events.
 //
 // These hooks capture:
-// - User login events
+// - Login events of every auth collection (including _superusers)
 // - Authentication method used
 // - Request metadata (IP, etc.)
 //
-// NOTE: Auth events are only logged for regular user authentication.
-// Admin/superuser authentication is not logged since admins are not
-// in the users collection.
+// Which auth collections are logged is controlled by the AuthCollections and
+// ExcludeAuthCollections options (see logsAuthFor).
 func registerAuthHooks(app *pocketbase.PocketBase, logger *logger) error {
 	app.OnRecordAuthRequest().BindFunc(func(e *core.RecordAuthRequestEvent) error {
 		if e.Record == nil {
 			return e.Next()
 		}
 
-		// Only log auth events for users in the users collection
-		// Skip admin/superuser authentication
-		if e.Record.Collection().Name != "users" {
+		// Skip auth collections that are not audited
+		if !logger.logsAuthFor(e.Record.Collection().Name) {
+			return e.Next()
+		}
+
+		// Token refreshes are logged as auth_refresh (see registerAuthLifecycleHooks)
+		if e.Get(authRefreshStoreKey) != nil {
 			return e.Next()
 		}
 
@@ -243,8 +378,11 @@ func registerAuthHooks(app *pocketbase.PocketBase, logger *logger) error {
 		// Add auth method
 		requestInfo[AuditLogFields.AuthMethod] = e.AuthMethod
 
-		// Add user ID
-		requestInfo[AuditLogFields.User] = e.Record.Id
+		// The authenticated record is the actor
+		applyActor(requestInfo, e.Record)
+
+		// Add request ID
+		requestInfo[AuditLogFields.RequestID] = requestID(e.RequestEvent)
 
 		// Extract IP and other request details
 		reqInfo, err := e.RequestInfo()
@@ -254,35 +392,227 @@ func registerAuthHooks(app *pocketbase.PocketBase, logger *logger) error {
 			requestInfo[AuditLogFields.RequestURL] = reqInfo.Context
 		}
 
+		// Only log once the auth response was written; errors (e.g. a failed
+		// token generation) are logged by the failure hooks
+		if err := e.Next(); err != nil {
+			return err
+		}
+
 		// Log auth event with current user state
 		if err := logger.logEvent(e.Record, nil, e.Record.Collection().Name, EventTypeAuth, requestInfo); err != nil {
-			if logger.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to log auth event: %v\n", err)
+			appLogger(logger.app).Warn("Failed to log auth event",
+				"collection", e.Record.Collection().Name, "record", e.Record.Id, "error", err)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+// registerAuthFailureHooks registers hooks for failed authentication attempts.
+//
+// OnRecordAuthRequest only fires once the credentials were accepted, so the
+// password, OTP and OAuth2 request hooks are wrapped instead: if the rest of
+// the chain returns an error, an auth_failed event is logged with the
+// attempted identity, the auth collection, the request metadata and the
+// reason. The error is passed on unchanged.
+//
+// A valid first factor that requires MFA (apis.ErrMFA) is not a failure; it
+// is logged as an mfa_challenge event instead (see logAuthError).
+//
+// PocketBase checks OTP codes before OnRecordAuthWithOTPRequest fires, so
+// unknown, expired and incorrect codes are logged by a router middleware on
+// the auth-with-otp route instead (see otpAuthMiddleware).
+//
+// NOTE: Password and OAuth2 requests rejected before these hooks run (e.g.
+// malformed bodies, disabled auth methods, rate limits) are not logged.
+func registerAuthFailureHooks(app *pocketbase.PocketBase, logger *logger) error {
+	// Hook: Auth with OTP route (codes rejected before the request hook),
+	// bound before the rate limiter so rate limited attempts are logged too
+	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+		se.Router.Bind(&hook.Handler[*core.RequestEvent]{
+			Func:     logger.otpAuthMiddleware,
+			Priority: apis.DefaultRateLimitMiddlewarePriority - 1,
+		})
+		return se.Next()
+	})
+
+	// Hook: Auth with password
+	app.OnRecordAuthWithPasswordRequest().BindFunc(func(e *core.RecordAuthWithPasswordRequestEvent) error {
+		err := e.Next()
+		if err != nil {
+			metadata := map[string]any{"identity": e.Identity}
+			if e.IdentityField != "" {
+				metadata["identity_field"] = e.IdentityField
 			}
+			logger.logAuthError(e.RequestEvent, e.Collection, e.Record, core.MFAMethodPassword, metadata, err)
 		}
+		return err
+	})
 
-		return e.Next()
+	// Hook: Auth with OTP
+	app.OnRecordAuthWithOTPRequest().BindFunc(func(e *core.RecordAuthWithOTPRequestEvent) error {
+		err := e.Next()
+		if err != nil {
+			metadata := map[string]any{}
+			if e.Record != nil {
+				metadata["identity"] = e.Record.Email()
+			}
+			if e.OTP != nil {
+				metadata["otp_id"] = e.OTP.Id
+			}
+			logger.logAuthError(e.RequestEvent, e.Collection, e.Record, core.MFAMethodOTP, metadata, err)
+
+			// Logged, so the route middleware doesn't log it again
+			e.Set(otpAuthLoggedStoreKey, true)
+		}
+		return err
+	})
+
+	// Hook: Auth with OAuth2
+	app.OnRecordAuthWithOAuth2Request().BindFunc(func(e *core.RecordAuthWithOAuth2RequestEvent) error {
+		err := e.Next()
+		if err != nil {
+			metadata := map[string]any{"provider": e.ProviderName}
+			if e.OAuth2User != nil {
+				metadata["identity"] = e.OAuth2User.Email
+				metadata["provider_user_id"] = e.OAuth2User.Id
+			}
+			logger.logAuthError(e.RequestEvent, e.Collection, e.Record, core.MFAMethodOAuth2, metadata, err)
+		}
+		return err
 	})
 
 	return nil
 }
 
-// extractRequestInfo extracts common request information from record request events.
+// otpAuthMiddleware logs failed auth-with-otp requests that were rejected
+// before OnRecordAuthWithOTPRequest fired: unknown, expired or incorrect OTP
+// codes, rate limits and invalid bodies. It runs before the built-in rate
+// limiter, which would otherwise reject the request first. The OTP and its auth record are
+// looked up from the submitted otpId when they still exist.
+func (l *logger) otpAuthMiddleware(e *core.RequestEvent) error {
+	if e.Request.Method != http.MethodPost || !strings.HasSuffix(e.Request.URL.Path, otpAuthRouteSuffix) {
+		return e.Next()
+	}
+
+	err := e.Next()
+	if err == nil || e.Get(otpAuthLoggedStoreKey) != nil {
+		return err
+	}
+
+	collection, findErr := l.app.FindCachedCollectionByNameOrId(e.Request.PathValue("collection"))
+	if findErr != nil || !collection.IsAuth() {
+		return err
+	}
+
+	metadata := map[string]any{}
+	var record *core.Record
+	if info, infoErr := e.RequestInfo(); infoErr == nil {
+		if otpID, _ := info.Body["otpId"].(string); otpID != "" {
+			metadata["otp_id"] = otpID
+			if otp, otpErr := l.app.FindOTPById(otpID); otpErr == nil && otp.CollectionRef() == collection.Id {
+				record, _ = l.app.FindRecordById(collection, otp.RecordRef())
+			}
+		}
+	}
+	if record != nil {
+		metadata["identity"] = record.Email()
+	}
+
+	l.logAuthError(e, collection, record, core.MFAMethodOTP, metadata, err)
+
+	return err
+}
+
+// logAuthError logs the outcome of an auth request that returned an error.
+//
+// BEHAVIOR:
+// - apis.ErrMFA (valid first factor, MFA required) is logged as mfa_challenge
+// - Any other error is logged as auth_failed with the reason in the metadata
+//
+// PARAMETERS:
+//   - e: Request event of the auth request
+//   - collection: Auth collection the attempt was made against
+//   - record: Auth record matching the identity (nil if none was found)
+//   - authMethod: Attempted auth method (password, otp, oauth2)
+//   - metadata: Attempted identity and method-specific details
+//   - authErr: Error returned by the auth request
+func (l *logger) logAuthError(
+	e *core.RequestEvent,
+	collection *core.Collection,
+	record *core.Record,
+	authMethod string,
+	metadata map[string]any,
+	authErr error,
+) {
+	recordID := ""
+	if record != nil {
+		recordID = record.Id
+	}
+
+	if errors.Is(authErr, apis.ErrMFA) {
+		l.logAuthEvent(e, collection, recordID, EventTypeMFAChallenge, authMethod, metadata)
+		return
+	}
+
+	// Describe why the attempt failed
+	metadata["reason"] = authErr.Error()
+	var apiErr *router.ApiError
+	if errors.As(authErr, &apiErr) {
+		metadata["status"] = apiErr.Status
+		if cause, ok := apiErr.RawData().(error); ok && cause.Error() != apiErr.Message {
+			metadata["error"] = cause.Error()
+		}
+	}
+
+	l.logAuthEvent(e, collection, recordID, EventTypeAuthFailed, authMethod, metadata)
+}
+
+// logsAuthFor reports whether authentication events of an auth collection
+// are logged.
+//
+// BEHAVIOR:
+// - Collections in ExcludeAuthCollections are never logged
+// - If AuthCollections is set, only the listed collections are logged
+// - Otherwise all auth collections are logged
+func (l *logger) logsAuthFor(collectionName string) bool {
+	if containsString(l.options.ExcludeAuthCollections, collectionName) {
+		return false
+	}
+
+	if len(l.options.AuthCollections) > 0 {
+		return containsString(l.options.AuthCollections, collectionName)
+	}
+
+	return true
+}
+
+// extractRequestInfo extracts common request information from request events.
 //
 // EXTRACTED DATA:
 // - Client IP address (via extractClientIP)
 // - HTTP method (GET, POST, PUT, DELETE, etc.)
 // - Request URL path
-// - Authenticated user ID (if available)
+// - Authenticated actor (collection, ID, email) if available
+// - Request ID (shared with the success events of the same request)
+// - Batch ID and operation index (batch sub-requests only)
 //
 // PARAMETERS:
-//   - e: Record request event
+//   - e: Request event (e.g. of a record request)
 //
 // RETURNS:
 //   - Map of request metadata
-func extractRequestInfo(e *core.RecordRequestEvent) map[string]interface{} {
+func extractRequestInfo(e *core.RequestEvent) map[string]interface{} {
 	requestInfo := make(map[string]interface{})
 
+	// Request ID links this event to the matching success event
+	requestInfo[AuditLogFields.RequestID] = requestID(e)
+
+	// Batch ID and operation index of /api/batch sub-requests
+	applyBatchInfo(requestInfo, e)
+
 	reqInfo, err := e.RequestInfo()
 	if err != nil {
 		return requestInfo
@@ -297,10 +627,8 @@ func extractRequestInfo(e *core.RecordRequestEvent) map[string]interface{} {
 	// Extract request URL
 	requestInfo[AuditLogFields.RequestURL] = reqInfo.Context
 
-	// Extract authenticated user if available
-	if reqInfo.Auth != nil {
-		requestInfo[AuditLogFields.User] = reqInfo.Auth.Id
-	}
+	// Extract the authenticated actor if available (any auth collection)
+	applyActor(requestInfo, reqInfo.Auth)
 
 	return requestInfo
 }
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestAuthWithOTP(t *testing.T) {
+	app := newTestApp(t, testOptions())
+
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.OTP.Enabled = true
+	if err := app.Save(users); err != nil {
+		t.Fatal(err)
+	}
+
+	user := core.NewRecord(users)
+	user.SetEmail("otp@example.com")
+	user.SetPassword("1234567890")
+	if err := app.Save(user); err != nil {
+		t.Fatal(err)
+	}
+
+	otp := core.NewOTP(app)
+	otp.SetCollectionRef(users.Id)
+	otp.SetRecordRef(user.Id)
+	otp.SetPassword("123456")
+	if err := app.Save(otp); err != nil {
+		t.Fatal(err)
+	}
+
+	url := "/api/collections/users/auth-with-otp"
+
+	// A wrong code is rejected before the OTP request hook fires
+	response := serveTestRequest(t, app, http.MethodPost, url, `{"otpId":"`+otp.Id+`","password":"000000"}`, nil)
+	if response.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for a wrong code, got %d", response.Code)
+	}
+
+	failures := findAuditRecords(t, app, EventTypeAuthFailed)
+	if len(failures) != 1 {
+		t.Fatalf("expected 1 auth_failed event, got %d", len(failures))
+	}
+	if failures[0].GetString(AuditLogFields.RecordID) != user.Id ||
+		failures[0].GetString(AuditLogFields.AuthMethod) != core.MFAMethodOTP {
+		t.Errorf("expected an otp failure of %s, got %v", user.Id, failures[0].FieldsData())
+	}
+
+	var metadata map[string]any
+	if err := failures[0].UnmarshalJSONField(AuditLogFields.Metadata, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata["otp_id"] != otp.Id || metadata["identity"] != user.Email() {
+		t.Errorf("expected the otp id and identity in the metadata, got %v", metadata)
+	}
+
+	// The right code logs a single auth event once the response was written
+	response = serveTestRequest(t, app, http.MethodPost, url, `{"otpId":"`+otp.Id+`","password":"123456"}`, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status 200 for the right code, got %d: %s", response.Code, response.Body.String())
+	}
+
+	if auths := findAuditRecords(t, app, EventTypeAuth); len(auths) != 1 {
+		t.Errorf("expected 1 auth event, got %d", len(auths))
+	}
+	if failures := findAuditRecords(t, app, EventTypeAuthFailed); len(failures) != 1 {
+		t.Errorf("expected no new auth_failed event, got %d in total", len(failures))
+	}
+}
+
+func TestAuthWithOTPRateLimited(t *testing.T) {
+	app := newTestApp(t, testOptions())
+
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users.OTP.Enabled = true
+	if err := app.Save(users); err != nil {
+		t.Fatal(err)
+	}
+
+	app.Settings().RateLimits.Enabled = true
+	app.Settings().RateLimits.Rules = []core.RateLimitRule{
+		{Label: "*:authWithOTP", MaxRequests: 1, Duration: 60},
+	}
+	if err := app.Save(app.Settings()); err != nil {
+		t.Fatal(err)
+	}
+
+	url := "/api/collections/users/auth-with-otp"
+	body := `{"otpId":"missing","password":"000000"}`
+
+	if response := serveTestRequest(t, app, http.MethodPost, url, body, nil); response.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400 for an unknown OTP, got %d", response.Code)
+	}
+	if response := serveTestRequest(t, app, http.MethodPost, url, body, nil); response.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 once rate limited, got %d", response.Code)
+	}
+
+	failures := findAuditRecords(t, app, EventTypeAuthFailed)
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 auth_failed events, got %d", len(failures))
+	}
+
+	var metadata map[string]any
+	if err := failures[1].UnmarshalJSONField(AuditLogFields.Metadata, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata["status"] != float64(http.StatusTooManyRequests) {
+		t.Errorf("expected the rate limited status in the metadata, got %v", metadata)
+	}
+}
@@ -0,0 +1,227 @@
+package audit
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// authRefreshStoreKey marks auth-refresh requests. PocketBase answers them
+// through OnRecordAuthRequest as well, which must not log them as logins.
+const authRefreshStoreKey = "pbAuditAuthRefresh"
+
+// registerAuthLifecycleHooks registers hooks for the account lifecycle of
+// auth records: recovery, verification, email changes, OTP requests, token
+// refreshes and OAuth2 links.
+//
+// The request hooks are wrapped and only log once the rest of the chain
+// succeeded, so an event is evidence that the step actually happened.
+// MFA challenges are logged by the auth failure hooks (see logAuthError).
+//
+// EVENTS:
+// - password_reset_request, password_reset_confirm
+// - verification_request, verification_confirm
+// - email_change_request, email_change_confirm (old and new email in the metadata)
+// - otp_request, auth_refresh
+// - oauth2_link: an _externalAuths record was created (OAuth2 sign-in or sign-up)
+// - oauth2_unlink: an _externalAuths record was deleted through the API
+func registerAuthLifecycleHooks(app *pocketbase.PocketBase, logger *logger) error {
+	// Hook: Password reset
+	app.OnRecordRequestPasswordResetRequest().BindFunc(func(e *core.RecordRequestPasswordResetRequestEvent) error {
+		err := e.Next()
+		if err == nil && e.Record != nil {
+			logger.logAuthEvent(e.RequestEvent, e.Collection, e.Record.Id, EventTypePasswordResetRequest, "", nil)
+		}
+		return err
+	})
+
+	app.OnRecordConfirmPasswordResetRequest().BindFunc(func(e *core.RecordConfirmPasswordResetRequestEvent) error {
+		err := e.Next()
+		if err == nil && e.Record != nil {
+			logger.logAuthEvent(e.RequestEvent, e.Collection, e.Record.Id, EventTypePasswordResetConfirm, "", nil)
+		}
+		return err
+	})
+
+	// Hook: Email verification
+	app.OnRecordRequestVerificationRequest().BindFunc(func(e *core.RecordRequestVerificationRequestEvent) error {
+		err := e.Next()
+		if err == nil && e.Record != nil {
+			logger.logAuthEvent(e.RequestEvent, e.Collection, e.Record.Id, EventTypeVerificationRequest, "", nil)
+		}
+		return err
+	})
+
+	app.OnRecordConfirmVerificationRequest().BindFunc(func(e *core.RecordConfirmVerificationRequestEvent) error {
+		err := e.Next()
+		if err == nil && e.Record != nil {
+			logger.logAuthEvent(e.RequestEvent, e.Collection, e.Record.Id, EventTypeVerificationConfirm, "", nil)
+		}
+		return err
+	})
+
+	// Hook: Email change
+	app.OnRecordRequestEmailChangeRequest().BindFunc(func(e *core.RecordRequestEmailChangeRequestEvent) error {
+		if e.Record == nil {
+			return e.Next()
+		}
+
+		metadata := map[string]any{"old_email": e.Record.Email(), "new_email": e.NewEmail}
+
+		err := e.Next()
+		if err == nil {
+			logger.logAuthEvent(e.RequestEvent, e.Collection, e.Record.Id, EventTypeEmailChangeRequest, "", metadata)
+		}
+		return err
+	})
+
+	app.OnRecordConfirmEmailChangeRequest().BindFunc(func(e *core.RecordConfirmEmailChangeRequestEvent) error {
+		if e.Record == nil {
+			return e.Next()
+		}
+
+		// Capture the old email before the confirmation changes it
+		metadata := map[string]any{"old_email": e.Record.Email(), "new_email": e.NewEmail}
+
+		err := e.Next()
+		if err == nil {
+			logger.logAuthEvent(e.RequestEvent, e.Collection, e.Record.Id, EventTypeEmailChangeConfirm, "", metadata)
+		}
+		return err
+	})
+
+	// Hook: OTP request (unknown emails get a dummy response and are not logged)
+	app.OnRecordRequestOTPRequest().BindFunc(func(e *core.RecordCreateOTPRequestEvent) error {
+		err := e.Next()
+		if err == nil && e.Record != nil {
+			logger.logAuthEvent(e.RequestEvent, e.Collection, e.Record.Id, EventTypeOTPRequest, core.MFAMethodOTP, nil)
+		}
+		return err
+	})
+
+	// Hook: Auth token refresh
+	app.OnRecordAuthRefreshRequest().BindFunc(func(e *core.RecordAuthRefreshRequestEvent) error {
+		e.Set(authRefreshStoreKey, true)
+
+		err := e.Next()
+		if err == nil && e.Record != nil {
+			logger.logAuthEvent(e.RequestEvent, e.Collection, e.Record.Id, EventTypeAuthRefresh, "", nil)
+		}
+		return err
+	})
+
+	// Hook: OAuth2 link - external auths are created by the OAuth2 flow with
+	// app.Save, so the success hook is used (no request metadata)
+	app.OnRecordAfterCreateSuccess(core.CollectionNameExternalAuths).BindFunc(func(e *core.RecordEvent) error {
+		logger.logExternalAuth(e.Record, EventTypeOAuth2Link)
+		return e.Next()
+	})
+
+	// Hook: OAuth2 unlink - external auths are deleted through the records API
+	app.OnRecordDeleteRequest(core.CollectionNameExternalAuths).BindFunc(func(e *core.RecordRequestEvent) error {
+		err := e.Next()
+		if err != nil {
+			return err
+		}
+
+		externalAuth := &core.ExternalAuth{Record: e.Record}
+
+		collection, findErr := logger.app.FindCachedCollectionByNameOrId(externalAuth.CollectionRef())
+		if findErr != nil {
+			appLogger(logger.app).Warn("Failed to find auth collection of unlinked external auth",
+				"externalAuth", e.Record.Id, "error", findErr)
+			return nil
+		}
+
+		logger.logAuthEvent(e.RequestEvent, collection, externalAuth.RecordRef(), EventTypeOAuth2Unlink,
+			core.MFAMethodOAuth2, externalAuthMetadata(externalAuth))
+
+		return nil
+	})
+
+	return nil
+}
+
+// logAuthEvent logs an authentication event of an auth record.
+//
+// The event is stored on the auth collection with the auth record as
+// record_id. No snapshot of the auth record is stored; event-specific
+// details go into the metadata.
+//
+// PARAMETERS:
+//   - e: Request event of the auth request
+//   - collection: Auth collection of the record
+//   - recordID: ID of the auth record (empty if unknown)
+//   - eventType: Type of event (see event type constants)
+//   - authMethod: Auth method involved, if any (password, otp, oauth2)
+//   - metadata: Event-specific details (nil = none)
+func (l *logger) logAuthEvent(
+	e *core.RequestEvent,
+	collection *core.Collection,
+	recordID string,
+	eventType string,
+	authMethod string,
+	metadata map[string]any,
+) {
+	if collection == nil || !l.logsAuthFor(collection.Name) {
+		return
+	}
+
+	requestInfo := map[string]interface{}{
+		AuditLogFields.RecordID:   recordID,
+		AuditLogFields.AuthMethod: authMethod,
+		AuditLogFields.RequestID:  requestID(e),
+	}
+	if len(metadata) > 0 {
+		requestInfo[AuditLogFields.Metadata] = metadata
+	}
+
+	// Extract IP, other request details and the authenticated actor (if any)
+	if reqInfo, err := e.RequestInfo(); err == nil {
+		requestInfo[AuditLogFields.RequestIP] = extractClientIP(reqInfo)
+		requestInfo[AuditLogFields.RequestMethod] = reqInfo.Method
+		requestInfo[AuditLogFields.RequestURL] = reqInfo.Context
+		applyActor(requestInfo, reqInfo.Auth)
+	}
+
+	if err := l.logEvent(nil, nil, collection.Name, eventType, requestInfo); err != nil {
+		appLogger(l.app).Warn("Failed to log auth event",
+			"eventType", eventType, "collection", collection.Name, "record", recordID, "error", err)
+	}
+}
+
+// logExternalAuth logs an OAuth2 link event from an _externalAuths record
+// outside of a request.
+func (l *logger) logExternalAuth(record *core.Record, eventType string) {
+	externalAuth := &core.ExternalAuth{Record: record}
+
+	collection, err := l.app.FindCachedCollectionByNameOrId(externalAuth.CollectionRef())
+	if err != nil {
+		appLogger(l.app).Warn("Failed to find auth collection of external auth",
+			"externalAuth", record.Id, "error", err)
+		return
+	}
+
+	if !l.logsAuthFor(collection.Name) {
+		return
+	}
+
+	requestInfo := map[string]interface{}{
+		AuditLogFields.RecordID:   externalAuth.RecordRef(),
+		AuditLogFields.AuthMethod: core.MFAMethodOAuth2,
+		AuditLogFields.Metadata:   externalAuthMetadata(externalAuth),
+	}
+
+	if err := l.logEvent(nil, nil, collection.Name, eventType, requestInfo); err != nil {
+		appLogger(l.app).Warn("Failed to log auth event",
+			"eventType", eventType, "collection", collection.Name, "record", externalAuth.RecordRef(), "error", err)
+	}
+}
+
+// externalAuthMetadata returns the metadata of an OAuth2 link or unlink event.
+func externalAuthMetadata(externalAuth *core.ExternalAuth) map[string]any {
+	return map[string]any{
+		"provider":         externalAuth.Provider(),
+		"provider_user_id": externalAuth.ProviderId(),
+		"external_auth_id": externalAuth.Id,
+	}
+}
@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestAuthRefresh(t *testing.T) {
+	app := newTestApp(t, testOptions())
+
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := core.NewRecord(users)
+	user.SetEmail("refresh@example.com")
+	user.SetPassword("1234567890")
+	if err := app.Save(user); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := user.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := serveTestRequest(t, app, http.MethodPost, "/api/collections/users/auth-refresh", "", map[string]string{
+		"Authorization": token,
+	})
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", response.Code, response.Body.String())
+	}
+
+	// A refresh is not a login
+	if auths := findAuditRecords(t, app, EventTypeAuth); len(auths) != 0 {
+		t.Errorf("expected no auth event, got %d", len(auths))
+	}
+
+	refreshes := findAuditRecords(t, app, EventTypeAuthRefresh)
+	if len(refreshes) != 1 {
+		t.Fatalf("expected 1 auth_refresh event, got %d", len(refreshes))
+	}
+	if refreshes[0].GetString(AuditLogFields.RecordID) != user.Id {
+		t.Errorf("expected the refresh of %s, got %s", user.Id, refreshes[0].GetString(AuditLogFields.RecordID))
+	}
+}
@@ -1,9 +1,12 @@
 package audit
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pocketbase/pocketbase"
@@ -14,20 +17,43 @@ import (
 type logger struct {
 	app     *pocketbase.PocketBase
 	options Options
+
+	// correlator links request events to their success events.
+	correlator correlator
+
+	// pendingBackup carries the request info of backup API requests to the backup hooks
+	pendingBackup pendingBackup
+
+	// sinks receives every audit event (the collection sink first)
+	sinks *sinkSet
+
+	// writer queues audit events for background writing (nil = synchronous)
+	writer *asyncWriter
+
+	// redactor redacts sensitive fields in snapshots (nil = disabled)
+	redactor *redactor
+
+	// batches holds the running batch requests by batch ID, whose events are
+	// buffered until the batch transaction finished (see registerBatchHooks)
+	batches sync.Map
 }
 
 // newLogger creates a new audit logger instance.
 func newLogger(app *pocketbase.PocketBase, options Options) *logger {
+	sinks := append([]Sink{newCollectionSink(app, options)}, options.Sinks...)
+
 	return &logger{
-		app:     app,
-		options: options,
+		app:      app,
+		options:  options,
+		sinks:    newSinkSet(app, options, sinks...),
+		redactor: newRedactor(options.Redaction, options.RedactionSalt),
 	}
 }
 
 // shouldLogEvent determines if an event should be logged based on options.
 //
 // FILTERING RULES:
-// 1. Never log events on the audit collection itself (prevents recursion)
+// 1. Never log record events on the audit collection itself (prevents recursion)
 // 2. Apply custom EventFilter if provided
 // 3. Default: log all events
 //
@@ -40,7 +66,8 @@ func newLogger(app *pocketbase.PocketBase, options Options) *logger {
 //   - false if event should be skipped
 func (l *logger) shouldLogEvent(collectionName string, eventType string) bool {
 	// Never log events on the audit collection itself to prevent recursion
-	if collectionName == l.options.CollectionName {
+	// (schema changes of the audit collection are logged, they can't recurse)
+	if collectionName == l.options.CollectionName && !isCollectionEvent(eventType) {
 		return false
 	}
 
@@ -53,10 +80,11 @@ func (l *logger) shouldLogEvent(collectionName string, eventType string) bool {
 	return true
 }
 
-// logEvent creates a new audit log record.
+// logEvent creates a new audit event and hands it to the sinks.
 //
 // This is the core logging function that handles all event types.
-// It captures the before/after states and request metadata.
+// It captures the before/after states, the field-level changes of updates
+// and request metadata.
 //
 // PARAMETERS:
 //   - afterRecord: Record state after operation (nil for delete)
@@ -67,7 +95,7 @@ func (l *logger) shouldLogEvent(collectionName string, eventType string) bool {
 //
 // RETURNS:
 //   - nil on success
-//   - error if audit log creation fails (logged but doesn't block operation)
+//   - error if a sink fails to write the event (logged but doesn't block operation)
 func (l *logger) logEvent(
 	afterRecord *core.Record,
 	beforeRecord *core.Record,
@@ -80,109 +108,327 @@ func (l *logger) logEvent(
 		return nil
 	}
 
-	// Find the audit logs collection
-	auditCollection, err := l.app.FindCollectionByNameOrId(l.options.CollectionName)
-	if err != nil {
-		if l.options.LogToConsole {
-			fmt.Printf("⚠️  WARNING Failed to find audit logs collection: %v\n", err)
-		}
-		return err
-	}
-
-	// Create new audit log record
-	auditRecord := core.NewRecord(auditCollection)
-
 	// Set basic audit information
-	auditRecord.Set(AuditLogFields.EventType, eventType)
-	auditRecord.Set(AuditLogFields.CollectionName, collectionName)
-	auditRecord.Set(AuditLogFields.Timestamp, time.Now())
+	event := Event{
+		ID:             core.GenerateDefaultRandomId(),
+		EventType:      eventType,
+		CollectionName: collectionName,
+		Timestamp:      time.Now(),
+	}
 
 	// Set record ID from either before or after record
-	var recordID string
 	if afterRecord != nil {
-		recordID = afterRecord.Id
+		event.RecordID = afterRecord.Id
 	} else if beforeRecord != nil {
-		recordID = beforeRecord.Id
-	}
-	// Only set if not empty (create_request events may not have ID yet)
-	if recordID != "" {
-		auditRecord.Set(AuditLogFields.RecordID, recordID)
+		event.RecordID = beforeRecord.Id
 	}
 
 	// Apply request information if available
-	if requestInfo != nil {
-		for key, value := range requestInfo {
-			// Special handling for user field - only set if it's a valid user ID
-			if key == AuditLogFields.User {
-				userID, ok := value.(string)
-				if ok && userID != "" {
-					// Verify the user exists in the users collection before setting relation
-					if l.isValidUser(userID) {
-						auditRecord.Set(key, value)
-					}
-					// If not valid, skip setting it (admin/superuser case)
-				}
-			} else {
-				auditRecord.Set(key, value)
-			}
-		}
-	}
+	applyRequestInfo(&event, requestInfo)
+
+	// Snapshot the before and after states. The changes are computed from
+	// the unredacted snapshots, so changed redacted fields still show up.
+	var before, after map[string]any
 
-	// Store before state if available
 	if beforeRecord != nil {
-		beforeJSON, err := json.Marshal(beforeRecord)
+		data, err := snapshotRecord(beforeRecord)
 		if err != nil {
-			if l.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to marshal before state: %v\n", err)
-			}
+			appLogger(l.app).Warn("Failed to marshal audit before state",
+				"collection", collectionName, "record", event.RecordID, "error", err)
 		} else {
-			auditRecord.Set(AuditLogFields.BeforeChanges, beforeJSON)
+			before = data
+			event.Before = l.redactor.redacted(collectionName, data)
 		}
 	}
 
-	// Store after state if available
 	if afterRecord != nil {
-		afterJSON, err := json.Marshal(afterRecord)
+		data, err := snapshotRecord(afterRecord)
 		if err != nil {
-			if l.options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Failed to marshal after state: %v\n", err)
-			}
+			appLogger(l.app).Warn("Failed to marshal audit after state",
+				"collection", collectionName, "record", event.RecordID, "error", err)
 		} else {
-			auditRecord.Set(AuditLogFields.AfterChanges, afterJSON)
+			after = data
+			event.After = l.redactor.redacted(collectionName, data)
 		}
 	}
 
-	// Save the audit log
-	if err := l.app.Save(auditRecord); err != nil {
-		if l.options.LogToConsole {
-			fmt.Printf("⚠️  WARNING Failed to save audit log: %v\n", err)
-		}
+	// Store field-level changes for update events (autodate fields left out)
+	if isUpdateEvent(eventType) && before != nil && after != nil {
+		changes := computeRecordChanges(afterRecord.Collection(), before, after)
+		event.Changes = l.redactor.redactChanges(collectionName, changes)
+	}
+
+	// Write the event to the sinks (or queue it in async mode)
+	if err := l.dispatch(event); err != nil {
 		return err
 	}
 
-	// Log to console if enabled
+	// Per-event line at debug level (hidden unless the log level allows it)
 	if l.options.LogToConsole {
-		fmt.Printf("📝 AUDIT %s event on %s record %s\n", eventType, collectionName, recordID)
+		appLogger(l.app).Debug("Audit event logged",
+			"eventType", eventType, "collection", collectionName, "record", event.RecordID, "eventId", event.ID)
 	}
 
 	return nil
 }
 
-// isValidUser checks if a user ID exists in the users collection.
+// dispatch writes an event to the sinks, either directly or through the async writer.
 //
-// This is necessary because authenticated users might be admins/superusers
-// who are not in the regular users collection. We only want to set the
-// user relation field if it's a valid user record.
+// Events of batch sub-requests (those with a batch index, unlike the batch
+// summary) are buffered on their batch instead: the sub-requests run in the
+// batch transaction, which holds the only write connection, so writing them
+// here would wait for the transaction forever.
+func (l *logger) dispatch(event Event) error {
+	if event.BatchIndex != nil {
+		if value, ok := l.batches.Load(event.BatchID); ok && value.(*batchContext).buffer(event) {
+			return nil
+		}
+
+		// Late event of a batch that timed out, whose transaction may still
+		// be running: write it once the transaction released the connection
+		go func() {
+			if err := l.write([]Event{event}); err != nil {
+				appLogger(l.app).Warn("Failed to log batch event",
+					"batch", event.BatchID, "eventType", event.EventType, "error", err)
+			}
+		}()
+		return nil
+	}
+
+	return l.write([]Event{event})
+}
+
+// write writes events to the sinks, either directly or through the async writer.
+func (l *logger) write(events []Event) error {
+	if l.writer == nil {
+		return l.sinks.write(events)
+	}
+
+	var errs []error
+	for _, event := range events {
+		if err := l.writer.enqueue(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyRequestInfo copies request metadata, keyed by audit log field name, to an event.
+func applyRequestInfo(event *Event, requestInfo map[string]interface{}) {
+	for key, value := range requestInfo {
+		s, _ := value.(string)
+		switch key {
+		case AuditLogFields.RecordID:
+			event.RecordID = s
+		case AuditLogFields.User:
+			event.User = s
+		case AuditLogFields.ActorCollection:
+			event.ActorCollection = s
+		case AuditLogFields.ActorID:
+			event.ActorID = s
+		case AuditLogFields.ActorEmail:
+			event.ActorEmail = s
+		case AuditLogFields.AuthMethod:
+			event.AuthMethod = s
+		case AuditLogFields.RequestMethod:
+			event.RequestMethod = s
+		case AuditLogFields.RequestIP:
+			event.RequestIP = s
+		case AuditLogFields.RequestURL:
+			event.RequestURL = s
+		case AuditLogFields.RequestID:
+			event.RequestID = s
+		case AuditLogFields.BatchID:
+			event.BatchID = s
+		case AuditLogFields.BatchIndex:
+			if index, ok := value.(int); ok {
+				event.BatchIndex = &index
+			}
+		case AuditLogFields.Metadata:
+			event.Metadata, _ = value.(map[string]any)
+		case AuditLogFields.Changes:
+			event.Changes, _ = value.(map[string]FieldChange)
+		}
+	}
+}
+
+// originalContextKey is the context key of the original state of a record
+// or collection being updated.
+type originalContextKey struct{}
+
+// originalState is the original state of a model, stored in the context of
+// the model event of a single save.
+type originalState struct {
+	model    any // The *core.Record or *core.Collection being saved
+	original any // Its state before the save
+	saved    any // Its state right after the save (records only, nil until saved)
+}
+
+// withOriginal returns a copy of ctx carrying the original state of a model.
 //
-// PARAMETERS:
-//   - userID: User ID to check
+// PocketBase passes the context of the update event on to the success and
+// error events of the same save, so the state is scoped to that save: saving
+// the same model twice (e.g. in a transaction) keeps both states apart, and
+// nothing outlives the save.
+func withOriginal(ctx context.Context, model any, original any) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, originalContextKey{}, &originalState{model: model, original: original})
+}
+
+// originalFrom returns the original state of a model stored in ctx by withOriginal.
+func originalFrom(ctx context.Context, model any) (any, bool) {
+	state := originalStateFrom(ctx, model)
+	if state == nil {
+		return nil, false
+	}
+	return state.original, true
+}
+
+// originalStateFrom returns the state of a model stored in ctx by withOriginal.
+func originalStateFrom(ctx context.Context, model any) *originalState {
+	if ctx == nil {
+		return nil
+	}
+
+	// A nested save of another model may inherit the context, so the model is checked
+	state, ok := ctx.Value(originalContextKey{}).(*originalState)
+	if !ok || state.model != model {
+		return nil
+	}
+	return state
+}
+
+// rememberOriginal stores the stored state of a record that is about to be
+// updated in the context of the update event.
 //
-// RETURNS:
-//   - true if user exists in users collection
-//   - false if user doesn't exist (e.g., admin/superuser)
-func (l *logger) isValidUser(userID string) bool {
-	_, err := l.app.FindRecordById("users", userID)
-	return err == nil
+// This is called before the update is executed (OnRecordUpdate), so it works for
+// every save: API requests, cron jobs, migrations and direct app.Save calls.
+// The row is loaded through the event's app, so saves inside a transaction
+// see the state left by the earlier saves of that transaction. Record.Original()
+// can't be used: it is only set when the record is loaded and isn't
+// refreshed after a save. The snapshot is picked up by the success hook via
+// takeOriginal.
+//
+// Call rememberSaved once the update was executed.
+func rememberOriginal(e *core.RecordEvent) error {
+	// The ID the row is stored under, in case the save changes it
+	id, _ := e.Record.LastSavedPK().(string)
+	if id == "" {
+		id = e.Record.Id
+	}
+
+	original, err := e.App.FindRecordById(e.Record.Collection(), id)
+	if err != nil {
+		return err
+	}
+
+	e.Context = withOriginal(e.Context, e.Record, original)
+	return nil
+}
+
+// rememberSaved stores the state of a record right after its update was
+// executed. Success hooks of a transaction only fire once it committed, so
+// by then the record may carry the values of later saves of the same
+// transaction.
+func rememberSaved(e *core.RecordEvent) {
+	if state := originalStateFrom(e.Context, e.Record); state != nil {
+		state.saved = e.Record.Clone()
+	}
+}
+
+// takeOriginal returns the states stored for the record of a success event:
+// before the update (nil if it couldn't be loaded) and right after it
+// (the event's record if it wasn't stored).
+func takeOriginal(e *core.RecordEvent) (*core.Record, *core.Record) {
+	state := originalStateFrom(e.Context, e.Record)
+	if state == nil {
+		return nil, e.Record
+	}
+
+	original, _ := state.original.(*core.Record)
+	saved, ok := state.saved.(*core.Record)
+	if !ok {
+		saved = e.Record
+	}
+	return original, saved
+}
+
+// requestInfoFor returns the request metadata remembered for a record (or
+// collection) by the request hooks, so success events share the request ID
+// and actor of the API request that caused them. Returns nil for saves made
+// outside of an API request.
+func (l *logger) requestInfoFor(model any) map[string]interface{} {
+	ctx, ok := l.correlator.take(model)
+	if !ok {
+		return nil
+	}
+
+	requestInfo := map[string]interface{}{
+		AuditLogFields.RequestID: ctx.requestID,
+	}
+	applyActor(requestInfo, ctx.actor)
+
+	if ctx.batchID != "" {
+		requestInfo[AuditLogFields.BatchID] = ctx.batchID
+		requestInfo[AuditLogFields.BatchIndex] = ctx.batchIndex
+	}
+
+	return requestInfo
+}
+
+// applyActor adds the actor fields of an authenticated record to request info.
+//
+// Any auth record is recorded as actor, including superusers and auth
+// collections other than users. The user relation is only set for records
+// of the users collection.
+func applyActor(requestInfo map[string]interface{}, actor *core.Record) {
+	if actor == nil {
+		return
+	}
+
+	requestInfo[AuditLogFields.ActorCollection] = actor.Collection().Name
+	requestInfo[AuditLogFields.ActorID] = actor.Id
+	requestInfo[AuditLogFields.ActorEmail] = actor.Email()
+
+	if actor.Collection().Name == "users" {
+		requestInfo[AuditLogFields.User] = actor.Id
+	}
+}
+
+// snapshotRecord converts a record to its JSON form as a generic map.
+//
+// The snapshot uses the record's regular JSON serialization, so hidden
+// fields (e.g. password hashes) are not included. It is not redacted yet:
+// redaction rules are applied to copies before they are stored (see
+// redactor.redacted and redactor.redactChanges).
+func snapshotRecord(record *core.Record) (map[string]any, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]any)
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// isUpdateEvent reports whether the event type describes a record update.
+func isUpdateEvent(eventType string) bool {
+	return eventType == EventTypeUpdate || eventType == EventTypeUpdateRequest
+}
+
+// appLogger returns the app's structured logger, tagged as pb-audit.
+//
+// Messages go wherever PocketBase sends its logs (the _logs table and the
+// admin log viewer, plus the console in dev mode) and respect the log
+// level configured in the settings.
+func appLogger(app core.App) *slog.Logger {
+	return app.Logger().With("component", "pb-audit")
 }
 
 // extractClientIP attempts to determine the real client IP address.
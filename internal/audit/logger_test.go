@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestUpdateOriginalPerSave(t *testing.T) {
+	scenarios := []struct {
+		name string
+		save func(t *testing.T, app core.App, record *core.Record)
+	}{
+		{
+			name: "record created in-process",
+			save: func(t *testing.T, app core.App, record *core.Record) {
+				for _, title := range []string{"v2", "v3"} {
+					record.Set("title", title)
+					if err := app.Save(record); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+		},
+		{
+			// Success hooks of a transaction only fire once it committed, so
+			// both saves are pending at the same time
+			name: "loaded record saved twice in a transaction",
+			save: func(t *testing.T, app core.App, record *core.Record) {
+				loaded, err := app.FindRecordById(record.Collection(), record.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = app.RunInTransaction(func(txApp core.App) error {
+					for _, title := range []string{"v2", "v3"} {
+						loaded.Set("title", title)
+						if err := txApp.Save(loaded); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := newTestApp(t, testOptions())
+			collection := newTestCollection(t, app, "notes")
+
+			record := core.NewRecord(collection)
+			record.Set("title", "v1")
+			if err := app.Save(record); err != nil {
+				t.Fatal(err)
+			}
+
+			s.save(t, app, record)
+
+			updates := findAuditRecords(t, app, EventTypeUpdate)
+			if len(updates) != 2 {
+				t.Fatalf("expected 2 update events, got %d", len(updates))
+			}
+
+			// Each save is diffed against the state the previous one stored
+			expected := []FieldChange{{Old: "v1", New: "v2"}, {Old: "v2", New: "v3"}}
+			for i, update := range updates {
+				var changes map[string]FieldChange
+				if err := update.UnmarshalJSONField(AuditLogFields.Changes, &changes); err != nil {
+					t.Fatal(err)
+				}
+
+				if len(changes) != 1 {
+					t.Errorf("update %d: expected only the title to change, got %v", i, changes)
+				}
+				if title := changes["title"]; title.Old != expected[i].Old || title.New != expected[i].New {
+					t.Errorf("update %d: expected title %v -> %v, got %v -> %v", i, expected[i].Old, expected[i].New, title.Old, title.New)
+				}
+			}
+		})
+	}
+}
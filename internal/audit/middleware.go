@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+const (
+	// MiddlewareId is the hook ID of the custom route middleware, so it can
+	// be unbound from single routes of an audited group.
+	MiddlewareId = "pbAuditMiddleware"
+
+	// loggerStoreKey is the app store key holding the audit logger, so the
+	// middleware (created before the app is bootstrapped) can find it.
+	loggerStoreKey = "pbAudit.logger"
+
+	// customFieldsStoreKey is the request event store key holding the
+	// business fields attached by the handler (see Annotate).
+	customFieldsStoreKey = "pbAuditFields"
+
+	// customTargetStoreKey is the request event store key holding the
+	// collection and record set by the handler (see AnnotateRecord).
+	customTargetStoreKey = "pbAuditTarget"
+
+	// customLoggedStoreKey marks requests already logged by an outer
+	// middleware (e.g. a group and one of its routes).
+	customLoggedStoreKey = "pbAuditLogged"
+
+	// defaultCustomCollectionName is the collection_name of custom_request
+	// events that don't name a collection.
+	defaultCustomCollectionName = "_custom"
+)
+
+// MiddlewareOptions configures the custom route middleware.
+type MiddlewareOptions struct {
+	Collection string                                            // collection_name of the events (default: "_custom")
+	Skip       func(e *core.RequestEvent) bool                   // Return true to not log a request
+	Enrich     func(e *core.RequestEvent, fields map[string]any) // Adds business fields after the handler ran
+}
+
+// customTarget is the collection and record a custom request acted on.
+type customTarget struct {
+	collectionName string
+	recordID       string
+}
+
+// NewMiddleware returns a router middleware that logs every request passing
+// through it as a custom_request event.
+//
+// The event carries the method, path, route pattern, actor, IP, response
+// status and duration, plus the business fields attached by the handler
+// (Annotate) or the Enrich hook in metadata.fields. The request is logged
+// after the handler returned, whether it succeeded or not.
+//
+// Requests handled before audit logging was initialized are not logged.
+func NewMiddleware(options MiddlewareOptions) *hook.Handler[*core.RequestEvent] {
+	if options.Collection == "" {
+		options.Collection = defaultCustomCollectionName
+	}
+
+	return &hook.Handler[*core.RequestEvent]{
+		Id: MiddlewareId,
+		Func: func(e *core.RequestEvent) error {
+			logger, ok := e.App.Store().Get(loggerStoreKey).(*logger)
+			if !ok {
+				return e.Next()
+			}
+
+			// Only the outermost middleware logs a request
+			if logged, _ := e.Get(customLoggedStoreKey).(bool); logged {
+				return e.Next()
+			}
+			if options.Skip != nil && options.Skip(e) {
+				return e.Next()
+			}
+			e.Set(customLoggedStoreKey, true)
+
+			// Resolve the request ID up front, so the X-Request-Id response
+			// header is set before the handler writes the response
+			requestID(e)
+
+			start := time.Now()
+			err := e.Next()
+			logger.logCustomRequest(e, options, err, time.Since(start))
+
+			return err
+		},
+	}
+}
+
+// Annotate attaches a business field to the custom_request event of a request.
+func Annotate(e *core.RequestEvent, key string, value any) {
+	fields, ok := e.Get(customFieldsStoreKey).(map[string]any)
+	if !ok {
+		fields = make(map[string]any)
+		e.Set(customFieldsStoreKey, fields)
+	}
+	fields[key] = value
+}
+
+// AnnotateRecord sets the collection and record the custom_request event of
+// a request refers to.
+func AnnotateRecord(e *core.RequestEvent, collectionName string, recordID string) {
+	e.Set(customTargetStoreKey, customTarget{collectionName: collectionName, recordID: recordID})
+}
+
+// logCustomRequest logs a request of a custom route.
+func (l *logger) logCustomRequest(e *core.RequestEvent, options MiddlewareOptions, handlerErr error, duration time.Duration) {
+	collectionName := options.Collection
+
+	requestInfo := extractRequestInfo(e)
+	requestInfo[AuditLogFields.RequestURL] = e.Request.URL.Path
+
+	if target, ok := e.Get(customTargetStoreKey).(customTarget); ok {
+		if target.collectionName != "" {
+			collectionName = target.collectionName
+		}
+		requestInfo[AuditLogFields.RecordID] = target.recordID
+	}
+
+	fields, _ := e.Get(customFieldsStoreKey).(map[string]any)
+	if options.Enrich != nil {
+		if fields == nil {
+			fields = make(map[string]any)
+		}
+		options.Enrich(e, fields)
+	}
+
+	metadata := map[string]any{
+		"path":        e.Request.URL.Path,
+		"pattern":     e.Request.Pattern,
+		"status":      responseStatus(e, handlerErr),
+		"duration_ms": duration.Milliseconds(),
+	}
+	if handlerErr != nil {
+		metadata["error"] = handlerErr.Error()
+	}
+	if len(fields) > 0 {
+		metadata["fields"] = fields
+	}
+	requestInfo[AuditLogFields.Metadata] = metadata
+
+	if err := l.logEvent(nil, nil, collectionName, EventTypeCustomRequest, requestInfo); err != nil {
+		appLogger(l.app).Warn("Failed to log custom request",
+			"path", e.Request.URL.Path, "error", err)
+	}
+}
+
+// responseStatus returns the status code of a handled request.
+//
+// Errors returned by the handler are only written to the response after the
+// middlewares, so their status is derived from the error.
+func responseStatus(e *core.RequestEvent, handlerErr error) int {
+	if handlerErr != nil {
+		var apiErr *router.ApiError
+		if errors.As(handlerErr, &apiErr) {
+			return apiErr.Status
+		}
+		return http.StatusInternalServerError
+	}
+
+	if status := e.Status(); status != 0 {
+		return status
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestMiddlewareRequestIDHeader(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		headers  map[string]string
+		expected string // empty = a generated ID
+	}{
+		{"generated ID", nil, ""},
+		{"incoming ID", map[string]string{requestIDHeader: "req-123"}, "req-123"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			app := newTestApp(t, testOptions())
+
+			app.OnServe().BindFunc(func(se *core.ServeEvent) error {
+				se.Router.GET("/custom", func(e *core.RequestEvent) error {
+					// Writes the response before the middleware logs the request
+					return e.String(http.StatusOK, "ok")
+				}).Bind(NewMiddleware(MiddlewareOptions{}))
+				return se.Next()
+			})
+
+			response := serveTestRequest(t, app, http.MethodGet, "/custom", "", s.headers)
+
+			// Result has the headers as they were when the response was written
+			header := response.Result().Header.Get(requestIDHeader)
+			if header == "" {
+				t.Fatal("expected the X-Request-Id response header to be set")
+			}
+			if s.expected != "" && header != s.expected {
+				t.Fatalf("expected the response header %q, got %q", s.expected, header)
+			}
+
+			records := findAuditRecords(t, app, EventTypeCustomRequest)
+			if len(records) != 1 {
+				t.Fatalf("expected 1 custom_request event, got %d", len(records))
+			}
+			if id := records[0].GetString(AuditLogFields.RequestID); id != header {
+				t.Fatalf("expected the logged request ID %q to match the header %q", id, header)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// readQueryParams are the query parameters stored with read events.
+var readQueryParams = []string{"filter", "sort", "expand"}
+
+// registerReadHooks registers hooks for read access to the collections
+// listed in ReadCollections.
+//
+// The request hooks are wrapped and only log once the response was sent,
+// so an event is evidence that the records were actually returned.
+// Read events carry no snapshots; the returned record IDs, the result count
+// and the filter/sort/expand query parameters go into the metadata.
+//
+// EVENTS:
+// - view: a single record was returned (record_id is set)
+// - list: a page of records was returned (record_ids in the metadata)
+func registerReadHooks(app *pocketbase.PocketBase, logger *logger) error {
+	// Hook: View
+	app.OnRecordViewRequest().BindFunc(func(e *core.RecordRequestEvent) error {
+		if !logger.logsReadsFor(e.Collection.Name) {
+			return e.Next()
+		}
+
+		err := e.Next()
+		if err == nil && e.Record != nil {
+			requestInfo := extractRequestInfo(e.RequestEvent)
+			requestInfo[AuditLogFields.RecordID] = e.Record.Id
+			requestInfo[AuditLogFields.Metadata] = readMetadata(e.RequestEvent, 1)
+
+			if err := logger.logEvent(nil, nil, e.Collection.Name, EventTypeView, requestInfo); err != nil {
+				appLogger(logger.app).Warn("Failed to log view request",
+					"collection", e.Collection.Name, "record", e.Record.Id, "error", err)
+			}
+		}
+		return err
+	})
+
+	// Hook: List
+	app.OnRecordsListRequest().BindFunc(func(e *core.RecordsListRequestEvent) error {
+		if !logger.logsReadsFor(e.Collection.Name) {
+			return e.Next()
+		}
+
+		err := e.Next()
+		if err == nil {
+			recordIDs := make([]string, 0, len(e.Records))
+			for _, record := range e.Records {
+				recordIDs = append(recordIDs, record.Id)
+			}
+
+			metadata := readMetadata(e.RequestEvent, len(e.Records))
+			metadata["record_ids"] = recordIDs
+			if e.Result != nil {
+				metadata["page"] = e.Result.Page
+				metadata["per_page"] = e.Result.PerPage
+				// TotalItems is -1 when the client asked to skip the total
+				if e.Result.TotalItems >= 0 {
+					metadata["total_items"] = e.Result.TotalItems
+				}
+			}
+
+			requestInfo := extractRequestInfo(e.RequestEvent)
+			requestInfo[AuditLogFields.Metadata] = metadata
+
+			if err := logger.logEvent(nil, nil, e.Collection.Name, EventTypeList, requestInfo); err != nil {
+				appLogger(logger.app).Warn("Failed to log list request",
+					"collection", e.Collection.Name, "count", len(e.Records), "error", err)
+			}
+		}
+		return err
+	})
+
+	return nil
+}
+
+// readMetadata returns the metadata shared by view and list events: the
+// number of returned records and the query parameters that shaped the result.
+func readMetadata(e *core.RequestEvent, count int) map[string]any {
+	metadata := map[string]any{"count": count}
+
+	reqInfo, err := e.RequestInfo()
+	if err != nil {
+		return metadata
+	}
+
+	query := make(map[string]any)
+	for _, param := range readQueryParams {
+		if value := reqInfo.Query[param]; value != "" {
+			query[param] = value
+		}
+	}
+	if len(query) > 0 {
+		metadata["query"] = query
+	}
+
+	return metadata
+}
+
+// logsReadsFor reports whether read access to a collection is logged.
+//
+// Reads are opt-in: only collections listed in ReadCollections are logged.
+func (l *logger) logsReadsFor(collectionName string) bool {
+	return containsString(l.options.ReadCollections, collectionName)
+}
@@ -0,0 +1,285 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// RedactAction defines how a sensitive field is redacted in snapshots.
+type RedactAction string
+
+const (
+	RedactDrop  RedactAction = "drop"   // Remove the field
+	RedactMask  RedactAction = "mask"   // Replace the value with a fixed mask
+	RedactHash  RedactAction = "hash"   // Replace the value with a salted SHA-256 hash
+	RedactLastN RedactAction = "last_n" // Keep only the last N characters
+)
+
+// redactedMask is the replacement value for masked fields.
+const redactedMask = "********"
+
+// RedactionRule configures redaction of sensitive fields in snapshots.
+type RedactionRule struct {
+	Collections []string     // Collections the rule applies to (empty = all collections)
+	Fields      []string     // Field name patterns (case-insensitive, path.Match syntax)
+	Action      RedactAction // How matching fields are redacted
+	KeepLast    int          // Number of characters kept by RedactLastN
+}
+
+// redactor applies redaction rules to record snapshots.
+//
+// RULE MATCHING:
+// - Rules scoped to collections are checked before global rules
+// - Within each group the first matching rule wins
+// - Patterns match field names at any depth, so keys inside JSON fields are covered too
+type redactor struct {
+	rules []RedactionRule
+	salt  string
+}
+
+// newRedactor creates a redactor. Returns nil if there are no rules.
+func newRedactor(rules []RedactionRule, salt string) *redactor {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	// Collection-scoped rules first, then global rules, each in the given order
+	ordered := make([]RedactionRule, 0, len(rules))
+	for _, rule := range rules {
+		if len(rule.Collections) > 0 {
+			ordered = append(ordered, rule)
+		}
+	}
+	for _, rule := range rules {
+		if len(rule.Collections) == 0 {
+			ordered = append(ordered, rule)
+		}
+	}
+
+	return &redactor{rules: ordered, salt: salt}
+}
+
+// apply redacts a record snapshot in place.
+func (r *redactor) apply(collectionName string, data map[string]any) {
+	if r == nil {
+		return
+	}
+
+	rules := r.rulesFor(collectionName)
+	if len(rules) == 0 {
+		return
+	}
+
+	r.redactMap(rules, data)
+}
+
+// redacted returns a redacted copy of a snapshot, leaving the snapshot unchanged.
+func (r *redactor) redacted(collectionName string, data map[string]any) map[string]any {
+	if r == nil {
+		return data
+	}
+
+	rules := r.rulesFor(collectionName)
+	if len(rules) == 0 {
+		return data
+	}
+
+	result := copyValue(data).(map[string]any)
+	r.redactMap(rules, result)
+	return result
+}
+
+// redactChanges returns a redacted copy of the field-level diff of two
+// snapshots of a collection's record.
+//
+// The diff must be computed from the unredacted snapshots: a changed field
+// matching a rule keeps its entry, so reviewers can see that it changed, with
+// both values redacted (dropped fields are masked). Nested values are
+// redacted like in the snapshots.
+func (r *redactor) redactChanges(collectionName string, changes map[string]FieldChange) map[string]FieldChange {
+	if r == nil || len(changes) == 0 {
+		return changes
+	}
+
+	rules := r.rulesFor(collectionName)
+	if len(rules) == 0 {
+		return changes
+	}
+
+	return r.redactChangesWith(rules, changes)
+}
+
+// redactChangesWith returns a redacted copy of a diff using the given rules.
+func (r *redactor) redactChangesWith(rules []RedactionRule, changes map[string]FieldChange) map[string]FieldChange {
+	if changes == nil {
+		return nil
+	}
+
+	result := make(map[string]FieldChange, len(changes))
+	for key, change := range changes {
+		if rule, ok := matchRule(rules, key); ok {
+			if rule.Action == RedactDrop {
+				rule.Action = RedactMask
+			}
+			result[key] = FieldChange{
+				Old: r.redactValue(rule, change.Old),
+				New: r.redactValue(rule, change.New),
+			}
+			continue
+		}
+
+		result[key] = FieldChange{
+			Old:     r.redactedValue(rules, change.Old),
+			New:     r.redactedValue(rules, change.New),
+			Added:   r.redactedList(rules, change.Added),
+			Removed: r.redactedList(rules, change.Removed),
+			Changes: r.redactChangesWith(rules, change.Changes),
+		}
+	}
+
+	return result
+}
+
+// redactedValue returns a copy of a decoded JSON value with nested keys redacted.
+func (r *redactor) redactedValue(rules []RedactionRule, value any) any {
+	result := copyValue(value)
+	r.redactNested(rules, result)
+	return result
+}
+
+// redactedList returns a copy of a list of decoded JSON values with nested keys redacted.
+func (r *redactor) redactedList(rules []RedactionRule, list []any) []any {
+	if list == nil {
+		return nil
+	}
+
+	result := make([]any, len(list))
+	for i, item := range list {
+		result[i] = r.redactedValue(rules, item)
+	}
+	return result
+}
+
+// rulesFor returns the rules that apply to a collection.
+func (r *redactor) rulesFor(collectionName string) []RedactionRule {
+	var rules []RedactionRule
+	for _, rule := range r.rules {
+		if len(rule.Collections) == 0 || containsString(rule.Collections, collectionName) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// redactMap redacts matching keys of a map, descending into nested values.
+func (r *redactor) redactMap(rules []RedactionRule, data map[string]any) {
+	for key, value := range data {
+		if rule, ok := matchRule(rules, key); ok {
+			if rule.Action == RedactDrop {
+				delete(data, key)
+			} else {
+				data[key] = r.redactValue(rule, value)
+			}
+			continue
+		}
+
+		r.redactNested(rules, value)
+	}
+}
+
+// redactNested descends into JSON objects and arrays.
+func (r *redactor) redactNested(rules []RedactionRule, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		r.redactMap(rules, v)
+	case []any:
+		for _, item := range v {
+			r.redactNested(rules, item)
+		}
+	}
+}
+
+// redactValue returns the redacted replacement of a value.
+func (r *redactor) redactValue(rule RedactionRule, value any) any {
+	// Empty values carry no sensitive data, keep them as they are
+	if value == nil || value == "" {
+		return value
+	}
+
+	switch rule.Action {
+	case RedactHash:
+		sum := sha256.Sum256([]byte(r.salt + valueString(value)))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	case RedactLastN:
+		keep := rule.KeepLast
+		if keep <= 0 {
+			keep = 4
+		}
+		runes := []rune(valueString(value))
+		if len(runes) <= keep {
+			return redactedMask
+		}
+		return redactedMask + string(runes[len(runes)-keep:])
+	default:
+		return redactedMask
+	}
+}
+
+// matchRule returns the first rule with a field pattern matching the key.
+func matchRule(rules []RedactionRule, key string) (RedactionRule, bool) {
+	key = strings.ToLower(key)
+	for _, rule := range rules {
+		for _, pattern := range rule.Fields {
+			if matched, _ := path.Match(strings.ToLower(pattern), key); matched {
+				return rule, true
+			}
+		}
+	}
+	return RedactionRule{}, false
+}
+
+// valueString converts a decoded JSON value to the string that is hashed or truncated.
+func valueString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(raw)
+}
+
+// copyValue returns a deep copy of a decoded JSON value.
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			result[key] = copyValue(item)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = copyValue(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+// containsString reports whether the list contains the value.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
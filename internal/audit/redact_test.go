@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactChanges(t *testing.T) {
+	r := newRedactor([]RedactionRule{
+		{Fields: []string{"*secret*"}, Action: RedactMask},
+		{Fields: []string{"notes"}, Action: RedactDrop},
+		{Fields: []string{"card"}, Action: RedactLastN, KeepLast: 2},
+	}, "")
+
+	scenarios := []struct {
+		name     string
+		before   map[string]any
+		after    map[string]any
+		expected map[string]FieldChange
+	}{
+		{
+			name:     "unredacted field",
+			before:   map[string]any{"title": "a"},
+			after:    map[string]any{"title": "b"},
+			expected: map[string]FieldChange{"title": {Old: "a", New: "b"}},
+		},
+		{
+			name:     "masked field keeps its entry",
+			before:   map[string]any{"api_secret": "old"},
+			after:    map[string]any{"api_secret": "new"},
+			expected: map[string]FieldChange{"api_secret": {Old: redactedMask, New: redactedMask}},
+		},
+		{
+			name:     "dropped field is masked",
+			before:   map[string]any{"notes": "old"},
+			after:    map[string]any{"notes": "new"},
+			expected: map[string]FieldChange{"notes": {Old: redactedMask, New: redactedMask}},
+		},
+		{
+			name:     "last_n field",
+			before:   map[string]any{"card": "4111"},
+			after:    map[string]any{"card": "4242"},
+			expected: map[string]FieldChange{"card": {Old: redactedMask + "11", New: redactedMask + "42"}},
+		},
+		{
+			name:   "nested secret in a JSON field",
+			before: map[string]any{"config": map[string]any{"secret": "old", "size": 1.0}},
+			after:  map[string]any{"config": map[string]any{"secret": "new", "size": 1.0}},
+			expected: map[string]FieldChange{"config": {
+				Old:     map[string]any{"secret": redactedMask, "size": 1.0},
+				New:     map[string]any{"secret": redactedMask, "size": 1.0},
+				Changes: map[string]FieldChange{"secret": {Old: redactedMask, New: redactedMask}},
+			}},
+		},
+		{
+			name:     "unchanged secret",
+			before:   map[string]any{"secret": "same", "title": "a"},
+			after:    map[string]any{"secret": "same", "title": "b"},
+			expected: map[string]FieldChange{"title": {Old: "a", New: "b"}},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			original := copyValue(s.before)
+
+			changes := r.redactChanges("notes", computeChanges(s.before, s.after))
+			if !reflect.DeepEqual(changes, s.expected) {
+				t.Errorf("expected %#v, got %#v", s.expected, changes)
+			}
+
+			// Redaction works on copies, the snapshots are left unchanged
+			r.redacted("notes", s.before)
+			if !reflect.DeepEqual(s.before, original) {
+				t.Errorf("expected the snapshot to be unchanged, got %#v", s.before)
+			}
+		})
+	}
+}
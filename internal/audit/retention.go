@@ -1,30 +1,62 @@
 package audit
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
 )
 
 const (
 	// retentionJobID is the cron job identifier for the retention cleanup task.
 	retentionJobID = "pb_audit_retention"
 
-	// retentionBatchSize is the number of records to delete per batch
-	// to avoid loading too many records into memory at once.
-	retentionBatchSize = 100
+	// retentionBatchSize is the number of records deleted per statement.
+	// Each batch is its own short transaction, so application writes can
+	// interleave between batches.
+	retentionBatchSize = 5000
+
+	// retentionArchiveBatchSize is the batch size when archiving is enabled,
+	// since archived records are loaded into memory before they are written.
+	retentionArchiveBatchSize = 1000
+
+	// retentionPause is the pause between batches to avoid starving application writes.
+	retentionPause = 50 * time.Millisecond
+
+	// retentionMaxFailures is the number of consecutive failed batches after
+	// which a cleanup gives up until the next scheduled run.
+	retentionMaxFailures = 3
+
+	// retentionProgressEvery is how often (in batches) progress is reported.
+	retentionProgressEvery = 20
 )
 
+// retentionRunning prevents overlapping cleanup runs when a run takes longer
+// than the cron interval.
+var retentionRunning atomic.Bool
+
+// retentionScope selects the audit records a cleanup applies to.
+type retentionScope struct {
+	name   string     // Name used in log messages (e.g. "max age")
+	where  string     // SQL condition, empty for all records
+	params dbx.Params // Parameters of the condition
+	limit  int        // Maximum number of records to delete (0 = no limit)
+}
+
 // registerRetention registers a cron job that periodically cleans up old audit logs.
 //
 // The cron job runs on the schedule defined by options.Retention.Interval and enforces
-// both MaxAge and MaxRecords constraints (whichever are set).
+// the age (MaxAge and Rules) and MaxRecords constraints (whichever are set).
 func registerRetention(app *pocketbase.PocketBase, options Options) error {
 	retention := options.Retention
 
-	// Nothing to do if neither constraint is set
-	if retention.MaxAge <= 0 && retention.MaxRecords <= 0 {
+	// Nothing to do if no constraint is set
+	if !retention.hasAgeLimit() && retention.MaxRecords <= 0 {
 		return nil
 	}
 
@@ -33,7 +65,7 @@ func registerRetention(app *pocketbase.PocketBase, options Options) error {
 	})
 
 	if options.LogToConsole {
-		fmt.Printf("✅ SUCCESS Retention policy cron job registered (schedule: %s)\n", retention.Interval)
+		appLogger(app).Debug("Audit retention job registered", "schedule", retention.Interval)
 	}
 
 	return nil
@@ -42,76 +74,177 @@ func registerRetention(app *pocketbase.PocketBase, options Options) error {
 // runRetention executes the retention cleanup logic.
 //
 // It enforces two independent constraints:
-// 1. MaxAge: deletes records with timestamp older than Now() - MaxAge
+// 1. Age: deletes records whose expires_at (resolved from Rules/MaxAge on write) has passed
 // 2. MaxRecords: if total count exceeds MaxRecords, deletes the oldest excess records
 //
+// If an archive policy is set, each batch is archived before it is deleted,
+// and the cleanup stops at the first batch that fails to archive.
+//
 // Errors are logged but never propagated — retention failures must not affect the application.
 func runRetention(app *pocketbase.PocketBase, options Options) {
-	retention := options.Retention
-
-	if options.LogToConsole {
-		fmt.Println("🧹 AUDIT  Running retention cleanup...")
+	if !retentionRunning.CompareAndSwap(false, true) {
+		appLogger(app).Warn("Audit retention cleanup still running, skipping this run")
+		return
 	}
+	defer retentionRunning.Store(false)
+
+	retention := options.Retention
+	started := time.Now()
 
 	// Age-based cleanup
-	if retention.MaxAge > 0 {
-		deleted := deleteByAge(app, options)
-		if options.LogToConsole && deleted > 0 {
-			fmt.Printf("🧹 AUDIT  Deleted %d records exceeding max age (%v)\n", deleted, retention.MaxAge)
-		}
+	expired := 0
+	if retention.hasAgeLimit() {
+		expired = deleteExpired(app, options)
 	}
 
 	// Count-based cleanup
+	excess := 0
 	if retention.MaxRecords > 0 {
-		deleted := deleteByCount(app, options)
-		if options.LogToConsole && deleted > 0 {
-			fmt.Printf("🧹 AUDIT  Deleted %d records exceeding max count (%d)\n", deleted, retention.MaxRecords)
-		}
+		excess = deleteByCount(app, options)
 	}
 
 	if options.LogToConsole {
-		fmt.Println("🧹 AUDIT  Retention cleanup complete")
+		appLogger(app).Info("Audit retention cleanup complete",
+			"expired", expired,
+			"excess", excess,
+			"maxRecords", retention.MaxRecords,
+			"duration", time.Since(started).Round(time.Millisecond).String(),
+		)
 	}
 }
 
-// deleteByAge deletes audit records older than MaxAge in batches.
+// deleteExpired deletes audit records whose retention expiry has passed.
+//
+// Records written before expires_at existed have no expiry; for those the
+// rules are applied to their timestamp instead (see legacyRetentionScopes).
+// Rules delete records from the middle of the log, so Setup rejects them when
+// the hash chain is enabled.
 // Returns the total number of records deleted.
-func deleteByAge(app *pocketbase.PocketBase, options Options) int {
-	cutoff := time.Now().Add(-options.Retention.MaxAge).UTC().Format("2006-01-02 15:04:05.000Z")
-	filter := fmt.Sprintf("%s < '%s'", AuditLogFields.Timestamp, cutoff)
+func deleteExpired(app *pocketbase.PocketBase, options Options) int {
+	now := time.Now()
 
-	totalDeleted := 0
-	for {
-		records, err := app.FindRecordsByFilter(
-			options.CollectionName,
-			filter,
-			AuditLogFields.Timestamp,
-			retentionBatchSize,
-			0,
-		)
-		if err != nil {
-			if options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Retention age query failed: %v\n", err)
-			}
-			break
+	deleted := deleteInBatches(app, options, retentionScope{
+		name:   "expiry",
+		where:  fmt.Sprintf("[[%[1]s]] != '' AND [[%[1]s]] < {:now}", AuditLogFields.ExpiresAt),
+		params: dbx.Params{"now": retentionTime(now)},
+	})
+
+	for _, scope := range legacyRetentionScopes(options.Retention, now) {
+		deleted += deleteInBatches(app, options, scope)
+	}
+
+	return deleted
+}
+
+// legacyRetentionScopes builds the scopes for records without expires_at.
+//
+// Each rule with a max age gets a scope selecting the records it matches (and
+// no earlier rule matches) that are older than its max age. MaxAge applies to
+// the records no rule matches. Records of rules that keep forever are never
+// selected, so new records without an expiry are left alone as well.
+func legacyRetentionScopes(retention *RetentionPolicy, now time.Time) []retentionScope {
+	noExpiry := fmt.Sprintf("[[%s]] = ''", AuditLogFields.ExpiresAt)
+
+	var scopes []retentionScope
+	for i, rule := range retention.Rules {
+		if rule.MaxAge <= 0 {
+			continue
 		}
 
-		if len(records) == 0 {
-			break
+		params := dbx.Params{"cutoff": retentionTime(now.Add(-rule.MaxAge))}
+		conditions := []string{noExpiry, rule.condition(fmt.Sprintf("r%d", i), params)}
+		for j, earlier := range retention.Rules[:i] {
+			conditions = append(conditions, "NOT "+earlier.condition(fmt.Sprintf("r%d", j), params))
 		}
+		conditions = append(conditions, fmt.Sprintf("[[%s]] < {:cutoff}", AuditLogFields.Timestamp))
 
-		for _, record := range records {
-			if err := app.Delete(record); err != nil {
-				if options.LogToConsole {
-					fmt.Printf("⚠️  WARNING Retention failed to delete record %s: %v\n", record.Id, err)
-				}
-			} else {
-				totalDeleted++
-			}
+		scopes = append(scopes, retentionScope{
+			name:   fmt.Sprintf("rule %d", i+1),
+			where:  strings.Join(conditions, " AND "),
+			params: params,
+		})
+	}
+
+	if retention.MaxAge > 0 {
+		params := dbx.Params{"cutoff": retentionTime(now.Add(-retention.MaxAge))}
+		conditions := []string{noExpiry}
+		for i, rule := range retention.Rules {
+			conditions = append(conditions, "NOT "+rule.condition(fmt.Sprintf("r%d", i), params))
 		}
+		conditions = append(conditions, fmt.Sprintf("[[%s]] < {:cutoff}", AuditLogFields.Timestamp))
+
+		scopes = append(scopes, retentionScope{
+			name:   "max age",
+			where:  strings.Join(conditions, " AND "),
+			params: params,
+		})
 	}
 
-	return totalDeleted
+	return scopes
+}
+
+// hasAgeLimit reports whether the policy deletes records by age.
+func (p *RetentionPolicy) hasAgeLimit() bool {
+	if p.MaxAge > 0 {
+		return true
+	}
+	for _, rule := range p.Rules {
+		if rule.MaxAge > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// maxAgeFor returns the max age of an audit record: the first matching rule's,
+// or MaxAge if no rule matches. Zero means the record never expires.
+func (p *RetentionPolicy) maxAgeFor(collectionName, eventType string) time.Duration {
+	for _, rule := range p.Rules {
+		if rule.matches(collectionName, eventType) {
+			return rule.MaxAge
+		}
+	}
+	return p.MaxAge
+}
+
+// matches reports whether the rule applies to a collection and event type.
+func (r RetentionRule) matches(collectionName, eventType string) bool {
+	if len(r.Collections) > 0 && !containsString(r.Collections, collectionName) {
+		return false
+	}
+	if len(r.EventTypes) > 0 && !containsString(r.EventTypes, eventType) {
+		return false
+	}
+	return true
+}
+
+// condition returns the SQL condition selecting the records the rule matches,
+// adding its parameters (named with the given prefix) to params.
+func (r RetentionRule) condition(prefix string, params dbx.Params) string {
+	conditions := []string{"1=1"}
+	if len(r.Collections) > 0 {
+		conditions = append(conditions, inCondition(AuditLogFields.CollectionName, prefix+"c", r.Collections, params))
+	}
+	if len(r.EventTypes) > 0 {
+		conditions = append(conditions, inCondition(AuditLogFields.EventType, prefix+"e", r.EventTypes, params))
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+// inCondition returns a "column IN (...)" condition with one named parameter per value.
+func inCondition(column, prefix string, values []string, params dbx.Params) string {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		name := fmt.Sprintf("%s%d", prefix, i)
+		params[name] = value
+		placeholders[i] = "{:" + name + "}"
+	}
+	return fmt.Sprintf("[[%s]] IN (%s)", column, strings.Join(placeholders, ", "))
+}
+
+// retentionTime formats a time like the stored date fields, for comparisons in SQL.
+func retentionTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.000Z")
 }
 
 // deleteByCount deletes the oldest audit records that exceed MaxRecords.
@@ -119,9 +252,7 @@ func deleteByAge(app *pocketbase.PocketBase, options Options) int {
 func deleteByCount(app *pocketbase.PocketBase, options Options) int {
 	total, err := app.CountRecords(options.CollectionName)
 	if err != nil {
-		if options.LogToConsole {
-			fmt.Printf("⚠️  WARNING Retention count query failed: %v\n", err)
-		}
+		appLogger(app).Warn("Audit retention count query failed", "error", err)
 		return 0
 	}
 
@@ -130,45 +261,216 @@ func deleteByCount(app *pocketbase.PocketBase, options Options) int {
 		return 0
 	}
 
+	return deleteInBatches(app, options, retentionScope{
+		name:  "max count",
+		limit: excess,
+	})
+}
+
+// deleteInBatches deletes the oldest records of a scope in bounded batches.
+//
+// ENGINE:
+//   - Each batch deletes up to retentionBatchSize rows with a single set-based
+//     DELETE (no per-record loading, no per-record hooks)
+//   - Batches are separate short transactions with a pause in between, so
+//     application writes are never blocked for long
+//   - Progress is reported every retentionProgressEvery batches
+//   - After retentionMaxFailures consecutive failed batches the cleanup gives
+//     up until the next scheduled run instead of retrying forever
+//
+// Returns the total number of records deleted.
+func deleteInBatches(app *pocketbase.PocketBase, options Options, scope retentionScope) int {
+	if options.HashChain && scope.where != "" {
+		var err error
+		if scope, err = chainPrefixScope(app, options, scope); err != nil {
+			appLogger(app).Warn("Audit retention range query failed", "scope", scope.name, "error", err)
+			return 0
+		}
+	}
+
+	batchSize := retentionBatchSize
+	if options.Retention.Archive != nil {
+		batchSize = retentionArchiveBatchSize
+	}
+
+	started := time.Now()
 	totalDeleted := 0
-	remaining := excess
+	batches := 0
+	failures := 0
 
-	for remaining > 0 {
-		batchSize := retentionBatchSize
-		if remaining < batchSize {
-			batchSize = remaining
+	for {
+		limit := batchSize
+		if scope.limit > 0 {
+			remaining := scope.limit - totalDeleted
+			if remaining <= 0 {
+				break
+			}
+			if remaining < limit {
+				limit = remaining
+			}
 		}
 
-		records, err := app.FindRecordsByFilter(
-			options.CollectionName,
-			"",
-			AuditLogFields.Timestamp,
-			batchSize,
-			0,
-		)
+		deleted, err := deleteBatch(app, options, scope, limit)
 		if err != nil {
-			if options.LogToConsole {
-				fmt.Printf("⚠️  WARNING Retention count-based query failed: %v\n", err)
+			failures++
+			appLogger(app).Warn("Audit retention batch failed",
+				"scope", scope.name, "failures", failures, "maxFailures", retentionMaxFailures, "error", err)
+			if failures >= retentionMaxFailures {
+				appLogger(app).Error("Audit retention cleanup giving up, will retry on next run",
+					"scope", scope.name, "failures", failures, "deleted", totalDeleted)
+				break
 			}
-			break
+
+			// Back off before retrying the same range
+			time.Sleep(time.Duration(failures) * time.Second)
+			continue
 		}
 
-		if len(records) == 0 {
-			break
+		failures = 0
+		batches++
+		totalDeleted += deleted
+
+		if options.LogToConsole && batches%retentionProgressEvery == 0 {
+			appLogger(app).Info("Audit retention progress",
+				"scope", scope.name, "deleted", totalDeleted, "duration", time.Since(started).Round(time.Second).String())
 		}
 
-		for _, record := range records {
-			if err := app.Delete(record); err != nil {
-				if options.LogToConsole {
-					fmt.Printf("⚠️  WARNING Retention failed to delete record %s: %v\n", record.Id, err)
-				}
-			} else {
-				totalDeleted++
-			}
+		// A partial batch means nothing is left in range
+		if deleted < limit {
+			break
 		}
 
-		remaining -= len(records)
+		time.Sleep(retentionPause)
 	}
 
 	return totalDeleted
 }
+
+// chainPrefixScope turns a scope into the rowid prefix of the audit log that
+// ends with the newest record of the scope.
+//
+// The hash chain links records in rowid order, but timestamps are not
+// strictly increasing with the rowid (spill replay and batch operations
+// write older events later). Deleting by timestamp could leave a gap in the
+// middle of the chain, which VerifyChain would report as tampering, so with
+// the hash chain only a prefix is deleted: records written before the
+// newest one in scope go with it, even if they haven't expired yet.
+func chainPrefixScope(app *pocketbase.PocketBase, options Options, scope retentionScope) (retentionScope, error) {
+	var maxRowid sql.NullInt64
+	err := app.DB().NewQuery(fmt.Sprintf(
+		"SELECT MAX(rowid) FROM {{%s}} WHERE %s",
+		options.CollectionName,
+		scope.where,
+	)).Bind(scope.params).Row(&maxRowid)
+	if err != nil {
+		return scope, err
+	}
+
+	// An empty prefix when nothing is in scope
+	return retentionScope{
+		name:   scope.name,
+		where:  "rowid <= {:maxRowid}",
+		params: dbx.Params{"maxRowid": maxRowid.Int64},
+		limit:  scope.limit,
+	}, nil
+}
+
+// retentionOrder returns the order in which retention deletes records:
+// oldest timestamp first, or insertion order with the hash chain (see
+// chainPrefixScope).
+func retentionOrder(options Options) []string {
+	if options.HashChain {
+		return []string{"rowid ASC"}
+	}
+	return []string{AuditLogFields.Timestamp + " ASC", "rowid ASC"}
+}
+
+// deleteBatch deletes up to limit of the oldest records of a scope.
+//
+// Without archiving this is a single statement:
+//
+//	DELETE FROM audit_logs WHERE rowid IN (
+//	    SELECT rowid FROM audit_logs WHERE ... ORDER BY timestamp, rowid LIMIT n
+//	)
+//
+// With the hash chain the records are ordered by rowid alone.
+//
+// With archiving, the batch is loaded and archived first, then deleted by ID
+// in one statement, so records are never deleted without a cold copy.
+//
+// RETURNS:
+//   - Number of records deleted
+//   - error if the batch failed (nothing was deleted)
+func deleteBatch(app *pocketbase.PocketBase, options Options, scope retentionScope, limit int) (int, error) {
+	if options.Retention.Archive != nil {
+		return archiveAndDeleteBatch(app, options, scope, limit)
+	}
+
+	where := ""
+	if scope.where != "" {
+		where = "WHERE " + scope.where
+	}
+
+	params := dbx.Params{"limit": limit}
+	for key, value := range scope.params {
+		params[key] = value
+	}
+
+	result, err := app.DB().NewQuery(fmt.Sprintf(
+		"DELETE FROM {{%[1]s}} WHERE rowid IN (SELECT rowid FROM {{%[1]s}} %[2]s ORDER BY %[3]s LIMIT {:limit})",
+		options.CollectionName,
+		where,
+		strings.Join(retentionOrder(options), ", "),
+	)).Bind(params).Execute()
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+// archiveAndDeleteBatch archives the oldest records of a scope, then deletes them.
+func archiveAndDeleteBatch(app *pocketbase.PocketBase, options Options, scope retentionScope, limit int) (int, error) {
+	query := app.RecordQuery(options.CollectionName).
+		OrderBy(retentionOrder(options)...).
+		Limit(int64(limit))
+	if scope.where != "" {
+		query.AndWhere(dbx.NewExp(scope.where, scope.params))
+	}
+
+	var records []*core.Record
+	if err := query.All(&records); err != nil {
+		return 0, err
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	// Never delete records that weren't archived
+	if err := archiveRecords(app, options, records); err != nil {
+		return 0, fmt.Errorf("archive failed: %w", err)
+	}
+
+	ids := make([]any, len(records))
+	for i, record := range records {
+		ids[i] = record.Id
+	}
+
+	result, err := app.DB().Delete(options.CollectionName, dbx.In("id", ids...)).Execute()
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
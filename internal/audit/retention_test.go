@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestRunRetention(t *testing.T) {
+	// Audit records in insertion order, by age; timestamps are not ordered
+	// like the rowids (e.g. after a spill replay)
+	ages := []time.Duration{2 * time.Hour, 30 * time.Minute, 90 * time.Minute, 0}
+
+	scenarios := []struct {
+		name      string
+		hashChain bool
+		retention RetentionPolicy
+		expected  []int // Indexes of the records left
+	}{
+		{
+			name:      "max age",
+			retention: RetentionPolicy{MaxAge: time.Hour},
+			expected:  []int{1, 3},
+		},
+		{
+			name:      "max age with hash chain deletes a prefix",
+			hashChain: true,
+			retention: RetentionPolicy{MaxAge: time.Hour},
+			expected:  []int{3},
+		},
+		{
+			name:      "max records",
+			retention: RetentionPolicy{MaxRecords: 2},
+			expected:  []int{1, 3},
+		},
+		{
+			name:      "max records with hash chain deletes a prefix",
+			hashChain: true,
+			retention: RetentionPolicy{MaxRecords: 2},
+			expected:  []int{2, 3},
+		},
+		{
+			name:      "legacy records without expiry",
+			retention: RetentionPolicy{MaxAge: time.Hour, Rules: []RetentionRule{{Collections: []string{"other"}}}},
+			expected:  []int{1, 3},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			options := testOptions()
+			options.HashChain = s.hashChain
+			options.LogSchemaEvents = false
+			app := newTestApp(t, options)
+			options.Retention = &s.retention
+
+			collection, err := app.FindCollectionByNameOrId("audit_logs")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var chain *hashChain
+			if s.hashChain {
+				chain = newHashChain("audit_logs")
+			}
+
+			now := time.Now()
+			ids := make([]string, len(ages))
+			for i, age := range ages {
+				timestamp, _ := types.ParseDateTime(now.Add(-age))
+
+				record := core.NewRecord(collection)
+				record.Set(AuditLogFields.EventType, EventTypeCreate)
+				record.Set(AuditLogFields.CollectionName, "notes")
+				record.Set(AuditLogFields.Timestamp, timestamp)
+				if len(s.retention.Rules) == 0 && s.retention.MaxAge > 0 {
+					record.Set(AuditLogFields.ExpiresAt, timestamp.Add(s.retention.MaxAge))
+				}
+				if err := insertRecords(app, chain, []*core.Record{record}); err != nil {
+					t.Fatal(err)
+				}
+				ids[i] = record.Id
+			}
+
+			runRetention(app, options)
+
+			var left []string
+			for _, record := range findAuditRecords(t, app, EventTypeCreate) {
+				left = append(left, record.Id)
+			}
+
+			var expected []string
+			for _, i := range s.expected {
+				expected = append(expected, ids[i])
+			}
+
+			if !slices.Equal(left, expected) {
+				t.Errorf("expected records %v to be left, got %v", s.expected, left)
+			}
+
+			if s.hashChain {
+				report, err := VerifyChain(app, "audit_logs")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !report.Valid {
+					t.Errorf("expected a valid chain after retention, broken at %s: %s", report.BrokenAt, report.Reason)
+				}
+			}
+		})
+	}
+}
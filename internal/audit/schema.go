@@ -0,0 +1,340 @@
+package audit
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// collectionRuleKeys are the JSON keys of the five API rules of a collection.
+var collectionRuleKeys = []string{"listRule", "viewRule", "createRule", "updateRule", "deleteRule"}
+
+// collectionSecretsRedactor hashes the token secrets and OAuth2 client secrets
+// of auth collections in collection snapshots. The salt is random per process,
+// so rotated secrets still show up in the diff without being recoverable.
+var collectionSecretsRedactor = newRedactor([]RedactionRule{
+	{Fields: []string{"secret", "clientSecret"}, Action: RedactHash},
+}, security.RandomString(32))
+
+// registerCollectionHooks registers hooks for collection schema changes.
+//
+// The collection model hooks fire for every save (admin UI, API, imports,
+// migrations and direct app.Save calls). The collection request hooks
+// remember the request ID and actor for the collection being saved, so
+// changes made through the API are attributed.
+//
+// The audit collection's own schema changes are logged as well, since
+// admins may customize it after setup.
+//
+// EVENTS:
+// - collection_create: after state only
+// - collection_update: before and after state, with a structured diff
+// - collection_delete: before state only
+func registerCollectionHooks(app *pocketbase.PocketBase, logger *logger) error {
+	// Hook: Request context for collections saved through the API
+	remember := func(e *core.CollectionRequestEvent) error {
+		logger.correlator.remember(e.Collection, requestContext{
+			requestID: requestID(e.RequestEvent),
+			actor:     e.Auth,
+		})
+		return e.Next()
+	}
+
+	app.OnCollectionCreateRequest().BindFunc(remember)
+	app.OnCollectionUpdateRequest().BindFunc(remember)
+	app.OnCollectionDeleteRequest().BindFunc(remember)
+
+	// Hook: Create Success
+	app.OnCollectionAfterCreateSuccess().BindFunc(func(e *core.CollectionEvent) error {
+		logger.logCollectionEvent(e.Collection, nil, EventTypeCollectionCreate, logger.requestInfoFor(e.Collection))
+		return e.Next()
+	})
+
+	// Hook: Update (before execution) - load the stored state, since the
+	// collection being saved already carries the new values
+	app.OnCollectionUpdate().BindFunc(func(e *core.CollectionEvent) error {
+		original, err := e.App.FindCollectionByNameOrId(e.Collection.Id)
+		if err != nil {
+			appLogger(logger.app).Warn("Failed to load original collection for update",
+				"collection", e.Collection.Name, "error", err)
+		} else {
+			e.Context = withOriginal(e.Context, e.Collection, original)
+		}
+
+		return e.Next()
+	})
+
+	// Hook: Update Success
+	app.OnCollectionAfterUpdateSuccess().BindFunc(func(e *core.CollectionEvent) error {
+		var original *core.Collection
+		if value, ok := originalFrom(e.Context, e.Collection); ok {
+			original = value.(*core.Collection)
+		}
+
+		logger.logCollectionEvent(e.Collection, original, EventTypeCollectionUpdate, logger.requestInfoFor(e.Collection))
+		return e.Next()
+	})
+
+	// Hook: Delete Success
+	app.OnCollectionAfterDeleteSuccess().BindFunc(func(e *core.CollectionEvent) error {
+		logger.logCollectionEvent(nil, e.Collection, EventTypeCollectionDelete, logger.requestInfoFor(e.Collection))
+		return e.Next()
+	})
+
+	// Hook: Errors - discard the request context of failed saves
+	forget := func(e *core.CollectionErrorEvent) error {
+		logger.correlator.forget(e.Collection)
+		return e.Next()
+	}
+
+	app.OnCollectionAfterCreateError().BindFunc(forget)
+	app.OnCollectionAfterUpdateError().BindFunc(forget)
+	app.OnCollectionAfterDeleteError().BindFunc(forget)
+
+	return nil
+}
+
+// logCollectionEvent logs a collection schema change.
+//
+// The event is stored with the collection's name as collection_name and its
+// ID as record_id. Snapshots hold the collection definition (fields,
+// indexes, API rules and options) with secrets hashed. Updates also get a
+// structured diff (see computeCollectionChanges) and the API rules that were
+// loosened are listed in the metadata.
+//
+// PARAMETERS:
+//   - after: Collection state after the change (nil for delete)
+//   - before: Collection state before the change (nil for create or if unknown)
+//   - eventType: Type of event (see event type constants)
+//   - requestInfo: Request metadata of the API request, if any
+func (l *logger) logCollectionEvent(
+	after *core.Collection,
+	before *core.Collection,
+	eventType string,
+	requestInfo map[string]interface{},
+) {
+	current := after
+	if current == nil {
+		current = before
+	}
+	if current == nil || !l.shouldLogEvent(current.Name, eventType) {
+		return
+	}
+
+	event := Event{
+		ID:             core.GenerateDefaultRandomId(),
+		EventType:      eventType,
+		CollectionName: current.Name,
+		RecordID:       current.Id,
+		Timestamp:      time.Now(),
+	}
+
+	applyRequestInfo(&event, requestInfo)
+
+	if before != nil {
+		data, err := snapshotCollection(before)
+		if err != nil {
+			appLogger(l.app).Warn("Failed to marshal collection before state",
+				"collection", current.Name, "error", err)
+		} else {
+			event.Before = data
+		}
+	}
+
+	if after != nil {
+		data, err := snapshotCollection(after)
+		if err != nil {
+			appLogger(l.app).Warn("Failed to marshal collection after state",
+				"collection", current.Name, "error", err)
+		} else {
+			event.After = data
+		}
+	}
+
+	if event.Before != nil && event.After != nil {
+		event.Changes = computeCollectionChanges(event.Before, event.After)
+
+		if loosened := loosenedRules(before, after); len(loosened) > 0 {
+			event.Metadata = map[string]any{"loosened_rules": loosened}
+		}
+	}
+
+	if err := l.dispatch(event); err != nil {
+		appLogger(l.app).Warn("Failed to log collection event",
+			"eventType", eventType, "collection", current.Name, "error", err)
+		return
+	}
+
+	if l.options.LogToConsole {
+		appLogger(l.app).Debug("Audit event logged",
+			"eventType", eventType, "collection", current.Name, "record", event.RecordID, "eventId", event.ID)
+	}
+}
+
+// snapshotCollection converts a collection to its JSON form as a generic map,
+// with token and OAuth2 client secrets hashed.
+//
+// The JSON form of a collection leaves the secrets out, so they are added
+// back before hashing; rotated secrets then show up in the diff.
+func snapshotCollection(collection *core.Collection) (map[string]any, error) {
+	// MarshalJSON blanks the client secrets in the providers slice it shares
+	// with the collection, so marshal a copy
+	clone := *collection
+	clone.OAuth2.Providers = slices.Clone(collection.OAuth2.Providers)
+
+	raw, err := json.Marshal(clone)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]any)
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	if collection.IsAuth() {
+		addCollectionSecrets(collection, data)
+	}
+
+	collectionSecretsRedactor.apply(collection.Name, data)
+
+	return data, nil
+}
+
+// addCollectionSecrets adds the token secrets and OAuth2 client secrets of an
+// auth collection to its JSON form.
+func addCollectionSecrets(collection *core.Collection, data map[string]any) {
+	tokens := map[string]string{
+		"authToken":          collection.AuthToken.Secret,
+		"fileToken":          collection.FileToken.Secret,
+		"passwordResetToken": collection.PasswordResetToken.Secret,
+		"emailChangeToken":   collection.EmailChangeToken.Secret,
+		"verificationToken":  collection.VerificationToken.Secret,
+	}
+	for key, secret := range tokens {
+		if token, ok := data[key].(map[string]any); ok && secret != "" {
+			token["secret"] = secret
+		}
+	}
+
+	oauth2, _ := data["oauth2"].(map[string]any)
+	providers, _ := oauth2["providers"].([]any)
+	for i, provider := range providers {
+		config, ok := provider.(map[string]any)
+		if ok && i < len(collection.OAuth2.Providers) && collection.OAuth2.Providers[i].ClientSecret != "" {
+			config["clientSecret"] = collection.OAuth2.Providers[i].ClientSecret
+		}
+	}
+}
+
+// computeCollectionChanges compares two collection snapshots.
+//
+// Fields are matched by ID, so renamed fields show up as changes rather than
+// as a removal plus an addition. The "fields" change lists the added and
+// removed field definitions and carries a nested diff per changed field,
+// keyed by the field's new name. Indexes list the added and removed
+// statements, and the API rules and options are diffed like record fields.
+func computeCollectionChanges(before, after map[string]any) map[string]FieldChange {
+	changes := computeChanges(
+		withoutKeys(before, "fields", "created", "updated"),
+		withoutKeys(after, "fields", "created", "updated"),
+	)
+
+	beforeFields, _ := before["fields"].([]any)
+	afterFields, _ := after["fields"].([]any)
+
+	oldByID := make(map[string]map[string]any, len(beforeFields))
+	for _, item := range beforeFields {
+		if field, ok := item.(map[string]any); ok {
+			id, _ := field["id"].(string)
+			oldByID[id] = field
+		}
+	}
+
+	fieldsChange := FieldChange{Old: beforeFields, New: afterFields}
+	fieldChanges := make(map[string]FieldChange)
+	seen := make(map[string]bool, len(afterFields))
+
+	for _, item := range afterFields {
+		field, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		id, _ := field["id"].(string)
+		seen[id] = true
+
+		old, ok := oldByID[id]
+		if !ok {
+			fieldsChange.Added = append(fieldsChange.Added, field)
+			continue
+		}
+
+		if change, changed := diffValues(old, field); changed {
+			name, _ := field["name"].(string)
+			fieldChanges[name] = change
+		}
+	}
+
+	for _, item := range beforeFields {
+		if field, ok := item.(map[string]any); ok {
+			id, _ := field["id"].(string)
+			if !seen[id] {
+				fieldsChange.Removed = append(fieldsChange.Removed, field)
+			}
+		}
+	}
+
+	if len(fieldChanges) > 0 {
+		fieldsChange.Changes = fieldChanges
+	}
+	if len(fieldsChange.Added) > 0 || len(fieldsChange.Removed) > 0 || len(fieldChanges) > 0 {
+		changes["fields"] = fieldsChange
+	}
+
+	return changes
+}
+
+// loosenedRules returns the API rules that grant more access after a change:
+// rules that were locked (superusers only) and now allow access, and rules
+// that were restricted by an expression and are now public.
+func loosenedRules(before, after *core.Collection) []string {
+	oldRules := []*string{before.ListRule, before.ViewRule, before.CreateRule, before.UpdateRule, before.DeleteRule}
+	newRules := []*string{after.ListRule, after.ViewRule, after.CreateRule, after.UpdateRule, after.DeleteRule}
+
+	var loosened []string
+	for i, key := range collectionRuleKeys {
+		oldRule, newRule := oldRules[i], newRules[i]
+		switch {
+		case newRule == nil:
+			// Locked, can't be looser than before
+		case oldRule == nil:
+			loosened = append(loosened, key)
+		case *oldRule != "" && *newRule == "":
+			loosened = append(loosened, key)
+		}
+	}
+
+	return loosened
+}
+
+// withoutKeys returns a shallow copy of a map without the given keys.
+func withoutKeys(data map[string]any, keys ...string) map[string]any {
+	result := make(map[string]any, len(data))
+	for key, value := range data {
+		if !containsString(keys, key) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// isCollectionEvent reports whether the event type describes a collection schema change.
+func isCollectionEvent(eventType string) bool {
+	return eventType == EventTypeCollectionCreate ||
+		eventType == EventTypeCollectionUpdate ||
+		eventType == EventTypeCollectionDelete
+}
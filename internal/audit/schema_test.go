@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCollectionUpdateHashesOAuth2ClientSecret(t *testing.T) {
+	app := newTestApp(t, testOptions())
+
+	collection := core.NewAuthCollection("members")
+	collection.OAuth2.Enabled = true
+	collection.OAuth2.Providers = []core.OAuth2ProviderConfig{
+		{Name: "google", ClientId: "client", ClientSecret: "old-client-secret"},
+	}
+	if err := app.Save(collection); err != nil {
+		t.Fatal(err)
+	}
+
+	collection.OAuth2.Providers[0].ClientSecret = "new-client-secret"
+	if err := app.Save(collection); err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshots must not touch the saved collection
+	if secret := collection.OAuth2.Providers[0].ClientSecret; secret != "new-client-secret" {
+		t.Errorf("expected the collection to keep its client secret, got %q", secret)
+	}
+
+	var update *core.Record
+	for _, record := range findAuditRecords(t, app, EventTypeCollectionUpdate) {
+		if record.GetString(AuditLogFields.CollectionName) == "members" {
+			update = record
+		}
+	}
+	if update == nil {
+		t.Fatal("expected a collection_update event of members")
+	}
+
+	// The rotated secret shows up in the diff, as hashes
+	var changes map[string]FieldChange
+	if err := update.UnmarshalJSONField(AuditLogFields.Changes, &changes); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := changes["oauth2"]; !ok {
+		t.Errorf("expected an oauth2 change, got %v", changes)
+	}
+
+	secrets := map[string]bool{}
+	for _, field := range []string{AuditLogFields.BeforeChanges, AuditLogFields.AfterChanges} {
+		var snapshot struct {
+			OAuth2 struct {
+				Providers []struct {
+					ClientSecret string `json:"clientSecret"`
+				} `json:"providers"`
+			} `json:"oauth2"`
+		}
+		if err := update.UnmarshalJSONField(field, &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		if len(snapshot.OAuth2.Providers) != 1 || !strings.HasPrefix(snapshot.OAuth2.Providers[0].ClientSecret, "sha256:") {
+			t.Fatalf("expected a hashed client secret in %s, got %+v", field, snapshot.OAuth2.Providers)
+		}
+		secrets[snapshot.OAuth2.Providers[0].ClientSecret] = true
+	}
+	if len(secrets) != 2 {
+		t.Errorf("expected different hashes for the old and new client secret")
+	}
+
+	for _, field := range []string{AuditLogFields.Changes, AuditLogFields.BeforeChanges, AuditLogFields.AfterChanges} {
+		raw := update.GetString(field)
+		if strings.Contains(raw, "old-client-secret") || strings.Contains(raw, "new-client-secret") {
+			t.Errorf("expected the client secrets to be hashed in %s, got %s", field, raw)
+		}
+	}
+}
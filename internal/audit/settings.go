@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"encoding/json"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// settingsCollectionName and settingsRecordID identify settings events:
+	// PocketBase stores the settings in the "settings" row of _params.
+	settingsCollectionName = "_params"
+	settingsRecordID       = "settings"
+)
+
+// settingsSecretsRedactor masks secrets in settings diffs (SMTP password, S3
+// and backups S3 secrets) regardless of the configured redaction rules.
+//
+// OAuth2 client secrets are not settings: they live in the oauth2 options of
+// each auth collection and are hashed by collectionSecretsRedactor in the
+// collection_update events (only logged with LogSchemaEvents).
+var settingsSecretsRedactor = newRedactor([]RedactionRule{
+	{Fields: []string{"*password*", "*secret*"}, Action: RedactMask},
+}, "")
+
+// registerSettingsHooks registers a hook for application settings changes.
+//
+// The settings update request hook is wrapped and only logs once the new
+// settings were saved. The event carries the diff of the changed settings
+// keys with secrets masked (changed secrets still show up, as masks), but no
+// snapshots, and the superuser who made the change as actor.
+func registerSettingsHooks(app *pocketbase.PocketBase, logger *logger) error {
+	app.OnSettingsUpdateRequest().BindFunc(func(e *core.SettingsUpdateRequestEvent) error {
+		// Snapshot the old settings before the chain replaces them
+		before, beforeErr := snapshotSettings(e.OldSettings)
+
+		err := e.Next()
+		if err != nil {
+			return err
+		}
+
+		after, afterErr := snapshotSettings(e.NewSettings)
+		if beforeErr != nil || afterErr != nil {
+			appLogger(logger.app).Warn("Failed to marshal settings for audit",
+				"before", beforeErr, "after", afterErr)
+			return nil
+		}
+
+		// Changed secrets keep their entry (so the change is visible), masked
+		changes := settingsSecretsRedactor.redactChanges(settingsCollectionName, computeChanges(before, after))
+
+		requestInfo := extractRequestInfo(e.RequestEvent)
+		requestInfo[AuditLogFields.RecordID] = settingsRecordID
+		requestInfo[AuditLogFields.Changes] = changes
+
+		if err := logger.logEvent(nil, nil, settingsCollectionName, EventTypeSettingsUpdate, requestInfo); err != nil {
+			appLogger(logger.app).Warn("Failed to log settings update", "error", err)
+		}
+
+		return nil
+	})
+
+	return nil
+}
+
+// snapshotSettings converts settings to their JSON form as a generic map.
+func snapshotSettings(settings *core.Settings) (map[string]any, error) {
+	data := make(map[string]any)
+	if settings == nil {
+		return data, nil
+	}
+
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	// The JSON form hides the secrets; add them so changed secrets are
+	// detected (they are masked before the diff is stored)
+	setSettingsValue(data, settings.SMTP.Password, "smtp", "password")
+	setSettingsValue(data, settings.S3.Secret, "s3", "secret")
+	setSettingsValue(data, settings.Backups.S3.Secret, "backups", "s3", "secret")
+
+	return data, nil
+}
+
+// setSettingsValue sets a nested value of a settings snapshot, creating
+// missing parent objects.
+func setSettingsValue(data map[string]any, value any, path ...string) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := data[key].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			data[key] = child
+		}
+		data = child
+	}
+	data[path[len(path)-1]] = value
+}
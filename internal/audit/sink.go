@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Event is a single audit event as delivered to sinks.
+//
+// The JSON form uses the audit log field names, so events written by file or
+// HTTP sinks line up with the records of the audit collection.
+type Event struct {
+	ID              string                 `json:"id"` // Unique event ID (also the ID of the audit record)
+	EventType       string                 `json:"event_type"`
+	CollectionName  string                 `json:"collection_name"`
+	RecordID        string                 `json:"record_id,omitempty"`
+	User            string                 `json:"user,omitempty"`             // ID of the actor if it is in the users collection
+	ActorCollection string                 `json:"actor_collection,omitempty"` // Auth collection of the actor (e.g. _superusers)
+	ActorID         string                 `json:"actor_id,omitempty"`
+	ActorEmail      string                 `json:"actor_email,omitempty"`
+	AuthMethod      string                 `json:"auth_method,omitempty"`
+	RequestMethod   string                 `json:"request_method,omitempty"`
+	RequestIP       string                 `json:"request_ip,omitempty"`
+	RequestURL      string                 `json:"request_url,omitempty"`
+	RequestID       string                 `json:"request_id,omitempty"`
+	BatchID         string                 `json:"batch_id,omitempty"`    // ID of the /api/batch request the operation was part of
+	BatchIndex      *int                   `json:"batch_index,omitempty"` // Position of the operation in the batch (0-based)
+	Timestamp       time.Time              `json:"timestamp"`
+	Before          map[string]any         `json:"before_changes,omitempty"` // Redacted record state before the operation
+	After           map[string]any         `json:"after_changes,omitempty"`  // Redacted record state after the operation
+	Changes         map[string]FieldChange `json:"changes,omitempty"`        // Field-level diff (update events only)
+	Metadata        map[string]any         `json:"metadata,omitempty"`       // Event-specific details (e.g. the reason of a failed login)
+}
+
+// Sink is a destination for audit events.
+//
+// Write receives events in the order they were logged: one at a time in
+// synchronous mode, in batches with the async writer. A returned error is
+// reported for that sink only; the other sinks still receive the events.
+//
+// Sinks that implement io.Closer are closed when the app terminates, after
+// all queued events were written.
+type Sink interface {
+	// Name identifies the sink in error reports and log messages.
+	Name() string
+
+	// Write delivers a batch of events.
+	Write(events []Event) error
+}
+
+// backgroundSink is implemented by built-in sinks that deliver events after
+// Write has returned. The sink set gives them a function to report failures
+// that happen in the background, so those reach OnSinkError as well.
+type backgroundSink interface {
+	Sink
+	setErrorReporter(report func(events []Event, err error))
+}
+
+// sinkSet fans events out to all configured sinks.
+//
+// Each sink is isolated: an error or panic in one sink is logged (and passed
+// to OnSinkError) and doesn't keep the events from the others.
+type sinkSet struct {
+	app     core.App
+	sinks   []Sink
+	options Options
+}
+
+// newSinkSet creates the fan-out for the given sinks, in order.
+func newSinkSet(app core.App, options Options, sinks ...Sink) *sinkSet {
+	s := &sinkSet{app: app, sinks: sinks, options: options}
+
+	for _, sink := range sinks {
+		if background, ok := sink.(backgroundSink); ok {
+			background.setErrorReporter(func(events []Event, err error) {
+				s.report(sink, events, err)
+			})
+		}
+	}
+
+	return s
+}
+
+// write delivers events to every sink.
+//
+// RETURNS:
+//   - nil if all sinks succeeded
+//   - the joined errors of the failed sinks
+func (s *sinkSet) write(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, sink := range s.sinks {
+		if err := writeSink(sink, events); err != nil {
+			s.report(sink, events, err)
+			errs = append(errs, fmt.Errorf("%s sink: %w", sink.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeCollectionFirst delivers events that must not be lost, such as
+// replayed spilled events: the audit collection (always the first sink) gets
+// them first, and only once it stored them do the other sinks. Returns the
+// error of the audit collection, so the caller can retry without duplicating
+// the events in the other sinks. Errors of the other sinks are reported like
+// in write.
+func (s *sinkSet) writeCollectionFirst(events []Event) error {
+	if len(events) == 0 || len(s.sinks) == 0 {
+		return nil
+	}
+
+	collection := s.sinks[0]
+	if err := writeSink(collection, events); err != nil {
+		s.report(collection, events, err)
+		return fmt.Errorf("%s sink: %w", collection.Name(), err)
+	}
+
+	for _, sink := range s.sinks[1:] {
+		if err := writeSink(sink, events); err != nil {
+			s.report(sink, events, err)
+		}
+	}
+
+	return nil
+}
+
+// report logs a sink error and hands it to OnSinkError if set.
+func (s *sinkSet) report(sink Sink, events []Event, err error) {
+	appLogger(s.app).Error("Audit sink failed to write events",
+		"sink", sink.Name(), "events", len(events), "error", err)
+
+	if s.options.OnSinkError != nil {
+		s.options.OnSinkError(sink.Name(), events, err)
+	}
+}
+
+// close closes all sinks that implement io.Closer.
+func (s *sinkSet) close() {
+	for _, sink := range s.sinks {
+		closer, ok := sink.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil {
+			appLogger(s.app).Warn("Failed to close audit sink", "sink", sink.Name(), "error", err)
+		}
+	}
+}
+
+// writeSink calls a sink, turning a panic into an error.
+func writeSink(sink Sink, events []Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sink panicked: %v", r)
+		}
+	}()
+
+	return sink.Write(events)
+}
+
+// fileSink appends events to a file as JSON lines.
+type fileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileSink creates a sink that appends each event as a JSON line to a file.
+// The file is created if it doesn't exist.
+func NewFileSink(path string) Sink {
+	return &fileSink{path: path}
+}
+
+// Name implements Sink.
+func (s *fileSink) Name() string {
+	return "file"
+}
+
+// Write implements Sink.
+func (s *fileSink) Write(events []Event) error {
+	var lines []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal audit event %s: %w", event.ID, err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(lines); err != nil {
+		return fmt.Errorf("failed to write audit file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package audit
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogNetwork is the transport used to reach the syslog server.
+type SyslogNetwork string
+
+const (
+	SyslogUDP SyslogNetwork = "udp" // One message per datagram (RFC 5426)
+	SyslogTCP SyslogNetwork = "tcp" // Octet-counting framing (RFC 6587)
+	SyslogTLS SyslogNetwork = "tls" // Octet-counting framing over TLS (RFC 5425)
+)
+
+// Syslog defaults.
+const (
+	syslogDefaultFacility = 13 // log audit
+	syslogDefaultSeverity = 5  // notice
+	syslogDefaultAppName  = "pocketbase"
+	syslogNilValue        = "-"
+)
+
+// SyslogOptions configures delivery of audit events to a syslog server.
+type SyslogOptions struct {
+	Network   SyslogNetwork // Transport (default: udp)
+	Address   string        // Server address as host:port
+	TLSConfig *tls.Config   // TLS configuration for SyslogTLS (default: system roots)
+	Facility  *int          // Syslog facility 0-23 (default: 13, log audit)
+	Severity  *int          // Syslog severity 0-7 (default: 5, notice)
+	AppName   string        // APP-NAME header field (default: "pocketbase")
+	Hostname  string        // HOSTNAME header field (default: os.Hostname())
+	SDID      string        // Structured data ID as name@<enterprise number> (required)
+	Timeout   time.Duration // Dial and write timeout (default: 5s)
+}
+
+// syslogSink sends each audit event as an RFC 5424 syslog message.
+//
+// MESSAGE FORMAT:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID params...] MSG
+//
+// MSGID is the event type. The structured data element carries the event
+// ID, event type, collection, record, actor, IP and request ID, so SIEMs
+// can parse the event without looking at MSG.
+//
+// The connection is opened on first use and re-established once if a write
+// fails. Writes are synchronous, so registerHooks requires Async for stream
+// transports to keep the network off the request path.
+type syslogSink struct {
+	options  SyslogOptions
+	facility int
+	severity int
+	procID   string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink creates a sink that sends audit events to a syslog server.
+func NewSyslogSink(options SyslogOptions) (Sink, error) {
+	if options.Address == "" {
+		return nil, errors.New("syslog address cannot be empty")
+	}
+
+	if options.Network == "" {
+		options.Network = SyslogUDP
+	}
+	switch options.Network {
+	case SyslogUDP, SyslogTCP, SyslogTLS:
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", options.Network)
+	}
+
+	facility := syslogDefaultFacility
+	if options.Facility != nil {
+		facility = *options.Facility
+	}
+	if facility < 0 || facility > 23 {
+		return nil, fmt.Errorf("syslog facility must be between 0 and 23, got %d", facility)
+	}
+
+	severity := syslogDefaultSeverity
+	if options.Severity != nil {
+		severity = *options.Severity
+	}
+	if severity < 0 || severity > 7 {
+		return nil, fmt.Errorf("syslog severity must be between 0 and 7, got %d", severity)
+	}
+
+	// SD-IDs without "@" are reserved for IANA registered names (RFC 5424 6.3.2)
+	if !isSDName(options.SDID) {
+		return nil, fmt.Errorf("syslog SD-ID must be at most 32 printable ASCII characters without space, '=', ']' or '\"', got %q", options.SDID)
+	}
+	name, enterpriseNumber, ok := strings.Cut(options.SDID, "@")
+	if !ok || name == "" || !isEnterpriseNumber(enterpriseNumber) {
+		return nil, fmt.Errorf("syslog SD-ID must be name@<enterprise number>, got %q", options.SDID)
+	}
+
+	if options.AppName == "" {
+		options.AppName = syslogDefaultAppName
+	}
+	if options.Hostname == "" {
+		options.Hostname, _ = os.Hostname()
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+
+	return &syslogSink{
+		options:  options,
+		facility: facility,
+		severity: severity,
+		procID:   strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+// isStream reports whether the sink writes to a TCP or TLS connection.
+func (s *syslogSink) isStream() bool {
+	return s.options.Network != SyslogUDP
+}
+
+// Name implements Sink.
+func (s *syslogSink) Name() string {
+	return "syslog:" + s.options.Address
+}
+
+// Write implements Sink.
+func (s *syslogSink) Write(events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		message := s.format(event)
+		if s.isStream() {
+			// Octet-counting framing: MSG-LEN SP SYSLOG-MSG
+			message = strconv.Itoa(len(message)) + " " + message
+		}
+
+		if err := s.send([]byte(message)); err != nil {
+			return fmt.Errorf("failed to send audit event %s: %w", event.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// Close implements io.Closer.
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// send writes a message, reconnecting once if the connection was lost.
+func (s *syslogSink) send(message []byte) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(); err != nil {
+				return err
+			}
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(s.options.Timeout))
+		if _, err = s.conn.Write(message); err == nil {
+			return nil
+		}
+
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	return err
+}
+
+// dial opens a connection to the syslog server.
+func (s *syslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.options.Timeout}
+
+	if s.options.Network == SyslogTLS {
+		config := s.options.TLSConfig
+		if config == nil {
+			host, _, _ := net.SplitHostPort(s.options.Address)
+			config = &tls.Config{ServerName: host}
+		}
+		return tls.DialWithDialer(dialer, "tcp", s.options.Address, config)
+	}
+
+	return dialer.Dial(string(s.options.Network), s.options.Address)
+}
+
+// format renders an event as an RFC 5424 message (without framing).
+func (s *syslogSink) format(event Event) string {
+	priority := s.facility*8 + s.severity
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		priority,
+		event.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderValue(s.options.Hostname, 255),
+		syslogHeaderValue(s.options.AppName, 48),
+		syslogHeaderValue(s.procID, 128),
+		syslogHeaderValue(event.EventType, 32),
+	)
+
+	// Structured data element, empty values are left out
+	b.WriteString("[" + s.options.SDID)
+	params := [][2]string{
+		{"event_id", event.ID},
+		{"event_type", event.EventType},
+		{"collection", event.CollectionName},
+		{"record", event.RecordID},
+		{"actor_collection", event.ActorCollection},
+		{"actor", event.ActorID},
+		{"ip", event.RequestIP},
+		{"method", event.RequestMethod},
+		{"request_id", event.RequestID},
+		{"batch_id", event.BatchID},
+	}
+	if event.BatchIndex != nil {
+		params = append(params, [2]string{"batch_index", strconv.Itoa(*event.BatchIndex)})
+	}
+	for _, param := range params {
+		if param[1] == "" {
+			continue
+		}
+		b.WriteString(" " + param[0] + `="` + syslogParamValue(param[1]) + `"`)
+	}
+	b.WriteString("] ")
+
+	// Human-readable message
+	b.WriteString(event.EventType + " " + event.CollectionName)
+	if event.RecordID != "" {
+		b.WriteString("/" + event.RecordID)
+	}
+
+	return b.String()
+}
+
+// syslogHeaderValue sanitizes a header field: printable US-ASCII without
+// spaces, truncated to limit characters, or the NILVALUE if empty.
+func syslogHeaderValue(value string, limit int) string {
+	var b strings.Builder
+	for _, r := range value {
+		if b.Len() >= limit {
+			break
+		}
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+	}
+
+	if b.Len() == 0 {
+		return syslogNilValue
+	}
+	return b.String()
+}
+
+// isSDName reports whether s is a valid SD-NAME (RFC 5424 6.3.2): 1 to 32
+// printable US-ASCII characters other than space, '=', ']' and '"'.
+func isSDName(s string) bool {
+	if len(s) == 0 || len(s) > 32 || !isPrintableASCII(s) {
+		return false
+	}
+	return !strings.ContainsAny(s, `=]"`)
+}
+
+// isEnterpriseNumber reports whether s is a private enterprise number,
+// optionally with sub-identifiers (e.g. "32473" or "32473.1").
+func isEnterpriseNumber(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// syslogParamValue escapes '"', '\' and ']' in a structured data parameter value.
+func syslogParamValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	return replacer.Replace(value)
+}
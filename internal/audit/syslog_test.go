@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+)
+
+func TestNewSyslogSink(t *testing.T) {
+	intPtr := func(value int) *int { return &value }
+
+	scenarios := []struct {
+		name           string
+		options        SyslogOptions
+		expectedError  string
+		expectedPrefix string
+	}{
+		{"defaults", SyslogOptions{Address: "localhost:514", SDID: "audit@12345"}, "", "<109>1 "},
+		{"kern and emerg", SyslogOptions{Address: "localhost:514", SDID: "audit@12345", Facility: intPtr(0), Severity: intPtr(0)}, "", "<0>1 "},
+		{"local7 and debug", SyslogOptions{Address: "localhost:514", SDID: "audit@12345", Facility: intPtr(23), Severity: intPtr(7)}, "", "<191>1 "},
+		{"facility out of range", SyslogOptions{Address: "localhost:514", SDID: "audit@12345", Facility: intPtr(24)}, "between 0 and 23", ""},
+		{"severity out of range", SyslogOptions{Address: "localhost:514", SDID: "audit@12345", Severity: intPtr(-1)}, "between 0 and 7", ""},
+		{"missing SD-ID", SyslogOptions{Address: "localhost:514"}, "SD-ID", ""},
+		{"SD-ID without enterprise number", SyslogOptions{Address: "localhost:514", SDID: "audit"}, "SD-ID", ""},
+		{"SD-ID with sub-identifier", SyslogOptions{Address: "localhost:514", SDID: "audit@12345.1"}, "", "<109>1 "},
+		{"SD-ID with non-numeric enterprise number", SyslogOptions{Address: "localhost:514", SDID: "audit@example"}, "SD-ID", ""},
+		{"SD-ID with two @", SyslogOptions{Address: "localhost:514", SDID: "audit@12345@1"}, "SD-ID", ""},
+		{"SD-ID with space", SyslogOptions{Address: "localhost:514", SDID: "au dit@12345"}, "SD-ID", ""},
+		{"SD-ID with =", SyslogOptions{Address: "localhost:514", SDID: "audit=x@12345"}, "SD-ID", ""},
+		{"SD-ID with ]", SyslogOptions{Address: "localhost:514", SDID: "audit]@12345"}, "SD-ID", ""},
+		{"SD-ID with quote", SyslogOptions{Address: "localhost:514", SDID: `audit"@12345`}, "SD-ID", ""},
+		{"SD-ID with non-ASCII", SyslogOptions{Address: "localhost:514", SDID: "audité@12345"}, "SD-ID", ""},
+		{"SD-ID with control character", SyslogOptions{Address: "localhost:514", SDID: "audit\t@12345"}, "SD-ID", ""},
+		{"SD-ID of 32 characters", SyslogOptions{Address: "localhost:514", SDID: strings.Repeat("a", 26) + "@12345"}, "", "<109>1 "},
+		{"SD-ID over 32 characters", SyslogOptions{Address: "localhost:514", SDID: strings.Repeat("a", 27) + "@12345"}, "SD-ID", ""},
+		{"missing address", SyslogOptions{SDID: "audit@12345"}, "address", ""},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			sink, err := NewSyslogSink(s.options)
+			if s.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), s.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", s.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			message := sink.(*syslogSink).format(Event{ID: "e1", EventType: EventTypeCreate, Timestamp: time.Now()})
+			if !strings.HasPrefix(message, s.expectedPrefix) {
+				t.Errorf("expected message starting with %q, got %q", s.expectedPrefix, message)
+			}
+			if !strings.Contains(message, "["+s.options.SDID+" ") {
+				t.Errorf("expected the configured SD-ID, got %q", message)
+			}
+		})
+	}
+}
+
+func TestSyslogStreamRequiresAsync(t *testing.T) {
+	app := pocketbase.NewWithConfig(pocketbase.Config{DefaultDataDir: t.TempDir(), HideStartBanner: true})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = app.ResetBootstrapState()
+	})
+
+	sink, err := NewSyslogSink(SyslogOptions{Network: SyslogTCP, Address: "localhost:6514", SDID: "audit@12345"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := testOptions()
+	options.Sinks = []Sink{sink}
+	if err := Initialize(app, options); err == nil || !strings.Contains(err.Error(), "requires Async") {
+		t.Fatalf("expected a sync TCP syslog sink to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,552 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// Webhook request headers.
+const (
+	webhookSignatureHeader = "X-PB-Audit-Signature"
+	webhookTimestampHeader = "X-PB-Audit-Timestamp"
+	webhookBatchHeader     = "X-PB-Audit-Batch"
+)
+
+// webhookFailedDir is the subdirectory of the queue directory for batches
+// that were rejected or exceeded MaxAttempts.
+const webhookFailedDir = "failed"
+
+// webhookPendingFile is the file in the queue directory that collects
+// written events (one JSON event per line) until they are cut into batches.
+const webhookPendingFile = "pending.jsonl"
+
+// WebhookOptions configures delivery of audit events to an HTTP endpoint.
+type WebhookOptions struct {
+	URL            string            // Endpoint receiving POST requests with JSON batches
+	Secret         string            // HMAC-SHA256 signing key
+	Headers        map[string]string // Extra request headers (e.g. Authorization)
+	BatchSize      int               // Maximum number of events per request (default: 100)
+	FlushInterval  time.Duration     // Maximum time an event waits before being sent (default: 5s)
+	Timeout        time.Duration     // Timeout of a single request (default: 10s)
+	InitialBackoff time.Duration     // Delay after the first failed attempt (default: 1s)
+	MaxBackoff     time.Duration     // Maximum delay between attempts (default: 5m)
+	MaxAttempts    int               // Attempts per batch before it is moved to failed/ (0 = retry forever)
+	QueueDir       string            // Persistent retry queue (default: pb_data/audit_webhook)
+}
+
+// webhookPayload is the JSON body of a webhook request.
+type webhookPayload struct {
+	Events []Event `json:"events"`
+}
+
+// webhookSink sends audit events to an HTTP endpoint in signed JSON batches.
+//
+// DELIVERY:
+//  1. Write appends events to the pending file in QueueDir and returns
+//  2. A worker cuts the pending file into batches every FlushInterval (or
+//     when BatchSize is reached) and stores each batch as a file in QueueDir
+//  3. Queued batches are sent oldest first; a batch file is removed once the
+//     endpoint answers with 2xx
+//  4. On failure, delivery pauses with exponential backoff and resumes with
+//     the same batch, so events are delivered in order
+//
+// Because events are on disk as soon as Write returns, undelivered events
+// survive crashes and restarts and are sent when the app starts again. If
+// the pending file can't be written, Write fails and the events are
+// reported to OnSinkError; nothing piles up in memory.
+type webhookSink struct {
+	name    string
+	options WebhookOptions
+	client  *http.Client
+
+	mu      sync.Mutex
+	pending int // Events in the pending file since the last cut
+	closed  bool
+	report  func(events []Event, err error)
+
+	failures    int       // Consecutive failed attempts of the oldest batch
+	nextAttempt time.Time // No attempts before this time (backoff)
+
+	flush chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewWebhookSink creates a sink that POSTs audit events to an HTTP endpoint.
+//
+// Every request is signed: the X-PB-Audit-Signature header holds
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)), where
+// timestamp is the X-PB-Audit-Timestamp header (Unix seconds). The
+// X-PB-Audit-Batch header identifies the batch across retries.
+//
+// The delivery worker starts immediately, so batches left over from the
+// last run are retried even before new events are logged.
+func NewWebhookSink(app core.App, options WebhookOptions) (Sink, error) {
+	if options.URL == "" {
+		return nil, errors.New("webhook URL cannot be empty")
+	}
+	endpoint, err := url.Parse(options.URL)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL %q", options.URL)
+	}
+	if options.Secret == "" {
+		return nil, errors.New("webhook secret cannot be empty")
+	}
+
+	if options.BatchSize <= 0 {
+		options.BatchSize = 100
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = 5 * time.Second
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 10 * time.Second
+	}
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = time.Second
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = 5 * time.Minute
+	}
+	if options.QueueDir == "" {
+		options.QueueDir = filepath.Join(app.DataDir(), "audit_webhook")
+	}
+
+	if err := os.MkdirAll(filepath.Join(options.QueueDir, webhookFailedDir), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create webhook queue directory: %w", err)
+	}
+
+	s := &webhookSink{
+		name:    "webhook:" + endpoint.Host,
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+		flush:   make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+// Name implements Sink.
+func (s *webhookSink) Name() string {
+	return s.name
+}
+
+// Write implements Sink. Events are appended to the pending file and sent by
+// the worker.
+func (s *webhookSink) Write(events []Event) error {
+	var lines []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal webhook event: %w", err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.pendingPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook events: %w", err)
+	}
+	_, err = file.Write(lines)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook events: %w", err)
+	}
+
+	s.pending += len(events)
+
+	// Worker already stopped, queue on disk for the next start
+	if s.closed {
+		return s.cutLocked()
+	}
+
+	if s.pending >= s.options.BatchSize {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Close stops the worker. Pending events are cut into batches, and the queue
+// gets one last delivery attempt bounded by Timeout.
+func (s *webhookSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	return nil
+}
+
+// setErrorReporter implements backgroundSink.
+func (s *webhookSink) setErrorReporter(report func(events []Event, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = report
+}
+
+// run is the delivery worker loop.
+func (s *webhookSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			s.cut()
+			s.deliver(time.Now().Add(s.options.Timeout))
+			return
+		case <-s.flush:
+			s.cut()
+			s.deliver(time.Time{})
+		case <-ticker.C:
+			s.cut()
+			s.deliver(time.Time{})
+		}
+	}
+}
+
+// cut moves the pending events to batch files.
+func (s *webhookSink) cut() {
+	s.mu.Lock()
+	err := s.cutLocked()
+	s.mu.Unlock()
+
+	if err != nil {
+		s.reportError(nil, err)
+	}
+}
+
+// cutLocked stores the events of the pending file as batch files and
+// removes it. Must hold mu.
+//
+// If a batch can't be stored, the pending file is rewritten with the events
+// that were not cut yet, so they are neither lost nor queued twice.
+func (s *webhookSink) cutLocked() error {
+	path := s.pendingPath()
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.pending = 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read pending webhook events: %w", err)
+	}
+
+	batch := make([]Event, 0, s.options.BatchSize)
+	lines := make([][]byte, 0, s.options.BatchSize)
+	var remainder [][]byte
+	var cutErr error
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if cutErr != nil {
+			remainder = append(remainder, slices.Clone(scanner.Bytes()))
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Skip lines that can't be restored (e.g. partially written on crash)
+			continue
+		}
+
+		batch = append(batch, event)
+		lines = append(lines, slices.Clone(scanner.Bytes()))
+		if len(batch) >= s.options.BatchSize {
+			if cutErr = s.enqueueBatch(batch); cutErr != nil {
+				remainder = append(remainder, lines...)
+			}
+			batch = batch[:0]
+			lines = lines[:0]
+		}
+	}
+	if cutErr == nil && len(batch) > 0 {
+		if cutErr = s.enqueueBatch(batch); cutErr != nil {
+			remainder = append(remainder, lines...)
+		}
+	}
+
+	scanErr := scanner.Err()
+	file.Close()
+
+	switch {
+	case scanErr != nil:
+		return fmt.Errorf("failed to read pending webhook events: %w", scanErr)
+	case cutErr != nil:
+		if err := rewriteLines(path, remainder); err != nil {
+			return errors.Join(cutErr, fmt.Errorf("failed to rewrite pending webhook events: %w", err))
+		}
+		s.pending = len(remainder)
+		return cutErr
+	}
+
+	s.pending = 0
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove pending webhook events: %w", err)
+	}
+	return nil
+}
+
+// pendingPath returns the path of the pending file.
+func (s *webhookSink) pendingPath() string {
+	return filepath.Join(s.options.QueueDir, webhookPendingFile)
+}
+
+// enqueueBatch writes a batch file to the queue directory.
+//
+// Batch files are named <unix nanos>_<random>.json so they sort in creation
+// order, and are written to a temporary file first so a crash never leaves a
+// partial batch behind.
+func (s *webhookSink) enqueueBatch(events []Event) error {
+	body, err := json.Marshal(webhookPayload{Events: events})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook batch: %w", err)
+	}
+
+	name := fmt.Sprintf("%020d_%s.json", time.Now().UnixNano(), security.RandomString(8))
+	path := filepath.Join(s.options.QueueDir, name)
+
+	if err := os.WriteFile(path+".tmp", body, 0o600); err != nil {
+		return fmt.Errorf("failed to write webhook batch: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write webhook batch: %w", err)
+	}
+
+	return nil
+}
+
+// deliver sends the queued batches, oldest first, until the queue is empty,
+// a send fails or the deadline passes (zero = no deadline).
+func (s *webhookSink) deliver(deadline time.Time) {
+	if time.Now().Before(s.nextAttempt) {
+		return
+	}
+
+	for {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return
+		}
+
+		batches, err := s.queuedBatches()
+		if err != nil {
+			s.reportError(nil, err)
+			return
+		}
+		if len(batches) == 0 {
+			return
+		}
+
+		if !s.deliverBatch(batches[0]) {
+			return
+		}
+
+		// Keep batches small while working off a backlog
+		s.cut()
+	}
+}
+
+// deliverBatch sends one batch file.
+//
+// RETURNS:
+//   - true if the batch is done (delivered or moved to failed/)
+//   - false if it must be retried later
+func (s *webhookSink) deliverBatch(name string) bool {
+	path := filepath.Join(s.options.QueueDir, name)
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		s.reportError(nil, fmt.Errorf("failed to read webhook batch: %w", err))
+		return false
+	}
+
+	err = s.send(strings.TrimSuffix(name, ".json"), body)
+	if err == nil {
+		s.failures = 0
+		s.nextAttempt = time.Time{}
+		if err := os.Remove(path); err != nil {
+			s.reportError(nil, fmt.Errorf("failed to remove delivered webhook batch: %w", err))
+			return false
+		}
+		return true
+	}
+
+	s.failures++
+
+	var permanent *webhookStatusError
+	giveUp := errors.As(err, &permanent) && permanent.permanent()
+	if !giveUp && s.options.MaxAttempts > 0 && s.failures >= s.options.MaxAttempts {
+		giveUp = true
+	}
+
+	if giveUp {
+		s.failures = 0
+		s.nextAttempt = time.Time{}
+		if renameErr := os.Rename(path, filepath.Join(s.options.QueueDir, webhookFailedDir, name)); renameErr != nil {
+			s.reportError(nil, fmt.Errorf("failed to move webhook batch to %s: %w", webhookFailedDir, renameErr))
+			return false
+		}
+		s.reportError(body, fmt.Errorf("batch moved to %s: %w", webhookFailedDir, err))
+		return true
+	}
+
+	// Report the first failure of a streak, not every retry
+	if s.failures == 1 {
+		s.reportError(body, fmt.Errorf("delivery failed, retrying with backoff: %w", err))
+	}
+
+	s.nextAttempt = time.Now().Add(s.backoff())
+	return false
+}
+
+// backoff returns the delay before the next attempt: InitialBackoff doubled
+// for every consecutive failure, capped at MaxBackoff.
+func (s *webhookSink) backoff() time.Duration {
+	delay := s.options.InitialBackoff
+	for i := 1; i < s.failures && delay < s.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.options.MaxBackoff)
+}
+
+// send POSTs a signed batch to the endpoint.
+func (s *webhookSink) send(batchID string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, s.options.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range s.options.Headers {
+		request.Header.Set(key, value)
+	}
+	request.Header.Set(webhookTimestampHeader, timestamp)
+	request.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(s.options.Secret, timestamp, body))
+	request.Header.Set(webhookBatchHeader, batchID)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// Drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &webhookStatusError{status: response.StatusCode}
+	}
+
+	return nil
+}
+
+// queuedBatches lists the batch files in the queue directory, oldest first.
+func (s *webhookSink) queuedBatches() ([]string, error) {
+	entries, err := os.ReadDir(s.options.QueueDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook queue: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// reportError passes a delivery error to the sink set, decoding the events
+// of the batch body if there is one.
+func (s *webhookSink) reportError(body []byte, err error) {
+	s.mu.Lock()
+	report := s.report
+	s.mu.Unlock()
+
+	if report == nil {
+		return
+	}
+
+	var payload webhookPayload
+	if body != nil {
+		json.Unmarshal(body, &payload)
+	}
+
+	report(payload.Events, err)
+}
+
+// signWebhook returns the hex HMAC-SHA256 of timestamp + "." + body.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookStatusError is returned for non-2xx responses.
+type webhookStatusError struct {
+	status int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook responded with status %d", e.status)
+}
+
+// permanent reports whether retrying can't succeed: client errors other than
+// timeouts and rate limiting.
+func (e *webhookStatusError) permanent() bool {
+	switch e.status {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return false
+	}
+	return e.status >= 400 && e.status < 500
+}
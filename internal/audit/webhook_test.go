@@ -0,0 +1,240 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookRequest is a request received by the test endpoint.
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newTestEndpoint starts an HTTP server that records every request and
+// answers with the next status of statuses (200 once they are used up).
+func newTestEndpoint(t *testing.T, statuses ...int) (*httptest.Server, func() []webhookRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []webhookRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		requests = append(requests, webhookRequest{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(requests) <= len(statuses) {
+			status = statuses[len(requests)-1]
+		}
+		mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(requests)
+	}
+}
+
+// newTestWebhookSink creates a webhook sink for the endpoint with short
+// backoff delays.
+func newTestWebhookSink(t *testing.T, endpoint, queueDir string, flushInterval time.Duration) *webhookSink {
+	t.Helper()
+
+	app := newTestApp(t, testOptions())
+	sink, err := NewWebhookSink(app, WebhookOptions{
+		URL:            endpoint,
+		Secret:         "test-secret",
+		Headers:        map[string]string{"Authorization": "Bearer token"},
+		BatchSize:      2,
+		FlushInterval:  flushInterval,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		QueueDir:       queueDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhook := sink.(*webhookSink)
+	t.Cleanup(func() { webhook.Close() })
+
+	return webhook
+}
+
+// waitForRequests waits until the endpoint received at least n requests.
+func waitForRequests(t *testing.T, requests func() []webhookRequest, n int) []webhookRequest {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if received := requests(); len(received) >= n {
+			return received
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("expected %d webhook requests, got %d", n, len(requests()))
+	return nil
+}
+
+// requestEventIDs returns the IDs of the events in a webhook request body.
+func requestEventIDs(t *testing.T, request webhookRequest) []string {
+	t.Helper()
+
+	var payload webhookPayload
+	if err := json.Unmarshal(request.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	return eventIDs(payload.Events)
+}
+
+func TestWebhookSinkSignature(t *testing.T) {
+	server, requests := newTestEndpoint(t)
+	sink := newTestWebhookSink(t, server.URL, t.TempDir(), 10*time.Millisecond)
+
+	if err := sink.Write(testEvents(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	request := waitForRequests(t, requests, 1)[0]
+
+	// Verified the way the README tells receivers to
+	mac := hmac.New(sha256.New, []byte("test-secret"))
+	mac.Write([]byte(request.header.Get(webhookTimestampHeader) + "."))
+	mac.Write(request.body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if signature := request.header.Get(webhookSignatureHeader); !hmac.Equal([]byte(expected), []byte(signature)) {
+		t.Fatalf("expected signature %s, got %s", expected, signature)
+	}
+
+	if request.header.Get(webhookBatchHeader) == "" {
+		t.Fatal("expected a batch ID header")
+	}
+	if auth := request.header.Get("Authorization"); auth != "Bearer token" {
+		t.Fatalf("expected the extra header to be sent, got %q", auth)
+	}
+	if ids := requestEventIDs(t, request); !slices.Equal(ids, []string{"e1", "e2"}) {
+		t.Fatalf("expected events e1 and e2, got %v", ids)
+	}
+}
+
+func TestWebhookSinkRetry(t *testing.T) {
+	server, requests := newTestEndpoint(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	sink := newTestWebhookSink(t, server.URL, t.TempDir(), 10*time.Millisecond)
+
+	var reported []error
+	var mu sync.Mutex
+	sink.setErrorReporter(func(events []Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	})
+
+	if err := sink.Write(testEvents(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	received := waitForRequests(t, requests, 4)
+
+	// The first batch is retried until it is delivered, then the second follows
+	batchIDs := make([]string, len(received))
+	for i, request := range received {
+		batchIDs[i] = request.header.Get(webhookBatchHeader)
+	}
+	if batchIDs[0] != batchIDs[1] || batchIDs[1] != batchIDs[2] || batchIDs[2] == batchIDs[3] {
+		t.Fatalf("expected the first batch to be retried with the same ID, got %v", batchIDs)
+	}
+	if ids := requestEventIDs(t, received[3]); !slices.Equal(ids, []string{"e3", "e4"}) {
+		t.Fatalf("expected the second batch after the retries, got %v", ids)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 1 {
+		t.Fatalf("expected only the first failure of the streak to be reported, got %v", reported)
+	}
+}
+
+func TestWebhookSinkPermanentFailure(t *testing.T) {
+	server, requests := newTestEndpoint(t, http.StatusBadRequest)
+	queueDir := t.TempDir()
+	sink := newTestWebhookSink(t, server.URL, queueDir, 10*time.Millisecond)
+
+	if err := sink.Write(testEvents(4)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rejected batch is not retried, delivery moves on
+	received := waitForRequests(t, requests, 2)
+	if ids := requestEventIDs(t, received[1]); !slices.Equal(ids, []string{"e3", "e4"}) {
+		t.Fatalf("expected the second batch after the rejected one, got %v", ids)
+	}
+
+	failed, err := os.ReadDir(filepath.Join(queueDir, webhookFailedDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 {
+		t.Fatalf("expected the rejected batch in %s, got %d files", webhookFailedDir, len(failed))
+	}
+}
+
+func TestWebhookSinkBackoff(t *testing.T) {
+	sink := &webhookSink{options: WebhookOptions{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range expected {
+		sink.failures = i + 1
+		if backoff := sink.backoff(); backoff != delay {
+			t.Errorf("failure %d: expected backoff %v, got %v", i+1, delay, backoff)
+		}
+	}
+}
+
+func TestWebhookSinkWriteThrough(t *testing.T) {
+	queueDir := t.TempDir()
+
+	// Nothing listens on the endpoint, so events stay queued
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	sink := newTestWebhookSink(t, unreachable.URL, queueDir, time.Hour)
+
+	if err := sink.Write(testEvents(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The event is on disk as soon as Write returns, before any flush
+	content, err := os.ReadFile(filepath.Join(queueDir, webhookPendingFile))
+	if err != nil {
+		t.Fatalf("expected the event in the pending file: %v", err)
+	}
+	var event Event
+	if err := json.Unmarshal(content, &event); err != nil || event.ID != "e1" {
+		t.Fatalf("expected event e1 in the pending file, got %s", content)
+	}
+
+	// A new sink on the same queue (e.g. after a crash) delivers it
+	server, requests := newTestEndpoint(t)
+	newTestWebhookSink(t, server.URL, queueDir, 10*time.Millisecond)
+
+	if ids := requestEventIDs(t, waitForRequests(t, requests, 1)[0]); !slices.Equal(ids, []string{"e1"}) {
+		t.Fatalf("expected the pending event to be delivered, got %v", ids)
+	}
+}
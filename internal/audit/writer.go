@@ -0,0 +1,320 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// OverflowPolicy decides what happens to an event when the async queue is full.
+type OverflowPolicy string
+
+const (
+	OverflowBlock OverflowPolicy = "block" // Wait for room in the queue
+	OverflowDrop  OverflowPolicy = "drop"  // Discard the event
+	OverflowSpill OverflowPolicy = "spill" // Append the event to a spill file on disk
+)
+
+// AsyncOptions configures asynchronous, batched writing of audit events.
+type AsyncOptions struct {
+	QueueSize     int            // Maximum number of events buffered in memory
+	BatchSize     int            // Maximum number of events written to the sinks at once
+	FlushInterval time.Duration  // Maximum time an event waits before being written
+	Overflow      OverflowPolicy // What to do when the queue is full
+	SpillPath     string         // Spill file for OverflowSpill (default: pb_data/audit_spill.jsonl)
+}
+
+// errQueueFull is returned when an event is dropped because the queue is full.
+var errQueueFull = errors.New("audit queue is full, event dropped")
+
+// asyncWriter writes audit events in the background.
+//
+// FLOW:
+// 1. logEvent enqueues the audit event (bounded channel)
+// 2. A single worker collects events into batches
+// 3. Each batch is written to all sinks when it is full or FlushInterval elapses
+// 4. On OnTerminate the queue is closed and drained before the app exits
+//
+// If the queue is full the Overflow policy applies. Spilled events are written
+// to a JSONL file and replayed by the worker once the queue has room again
+// (and on the next start if the app exits first).
+type asyncWriter struct {
+	app     *pocketbase.PocketBase
+	options Options
+	async   AsyncOptions
+	sinks   *sinkSet
+
+	queue chan Event
+	done  chan struct{}
+
+	// mu guards closed; senders hold the read lock while enqueueing
+	mu     sync.RWMutex
+	closed bool
+
+	spillMu sync.Mutex
+}
+
+// newAsyncWriter creates an async writer. Call start to launch the worker.
+func newAsyncWriter(app *pocketbase.PocketBase, options Options, sinks *sinkSet) *asyncWriter {
+	async := *options.Async
+	if async.SpillPath == "" {
+		async.SpillPath = filepath.Join(app.DataDir(), "audit_spill.jsonl")
+	}
+
+	return &asyncWriter{
+		app:     app,
+		options: options,
+		async:   async,
+		sinks:   sinks,
+		queue:   make(chan Event, async.QueueSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// start launches the background worker and flushes the queue on app termination.
+func (w *asyncWriter) start() {
+	go w.run()
+
+	w.app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		w.stop()
+		return e.Next()
+	})
+}
+
+// stop closes the queue and waits until all queued events are written.
+// Events logged after stop are written synchronously.
+func (w *asyncWriter) stop() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+// enqueue adds an audit event to the queue, applying the overflow policy if full.
+func (w *asyncWriter) enqueue(event Event) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	// Queue already drained on shutdown, write directly
+	if w.closed {
+		return w.sinks.write([]Event{event})
+	}
+
+	switch w.async.Overflow {
+	case OverflowDrop:
+		select {
+		case w.queue <- event:
+			return nil
+		default:
+			return errQueueFull
+		}
+	case OverflowSpill:
+		select {
+		case w.queue <- event:
+			return nil
+		default:
+			return w.spill(event)
+		}
+	default:
+		w.queue <- event
+		return nil
+	}
+}
+
+// run is the worker loop. It exits once the queue is closed and drained.
+func (w *asyncWriter) run() {
+	defer close(w.done)
+
+	// Replay events spilled before the last shutdown
+	w.replaySpill()
+
+	ticker := time.NewTicker(w.async.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, w.async.BatchSize)
+	for {
+		select {
+		case event, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				w.replaySpill()
+				return
+			}
+
+			batch = append(batch, event)
+			if len(batch) >= w.async.BatchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+
+			// Only replay spilled events once the queue has caught up
+			if len(w.queue) == 0 {
+				w.replaySpill()
+			}
+		}
+	}
+}
+
+// flush writes a batch of audit events to all sinks.
+//
+// Sink errors are reported by the sink set; the worker moves on to the next batch.
+func (w *asyncWriter) flush(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	// The worker reuses the batch slice, so sinks get their own copy
+	events := make([]Event, len(batch))
+	copy(events, batch)
+
+	w.sinks.write(events)
+}
+
+// spill appends an audit event to the spill file.
+func (w *asyncWriter) spill(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spilled audit event: %w", err)
+	}
+
+	w.spillMu.Lock()
+	defer w.spillMu.Unlock()
+
+	file, err := os.OpenFile(w.async.SpillPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit spill file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit spill file: %w", err)
+	}
+
+	return nil
+}
+
+// replaySpill writes all spilled events to the sinks and removes the spill file.
+//
+// The spill file is first renamed so new spills can continue while replaying.
+// A replay file left over from a crash or a failed replay is processed before
+// the spill file.
+//
+// If the audit collection fails to store a batch, the replay stops and the
+// replay file is rewritten with the events that were not written yet, to be
+// retried on the next replay (see sinkSet.writeCollectionFirst).
+func (w *asyncWriter) replaySpill() {
+	replayPath := w.async.SpillPath + ".replay"
+
+	if _, err := os.Stat(replayPath); err != nil {
+		w.spillMu.Lock()
+		err := os.Rename(w.async.SpillPath, replayPath)
+		w.spillMu.Unlock()
+		if err != nil {
+			// Nothing spilled
+			return
+		}
+	}
+
+	file, err := os.Open(replayPath)
+	if err != nil {
+		appLogger(w.app).Error("Failed to open audit spill file", "path", replayPath, "error", err)
+		return
+	}
+
+	total := 0
+	batch := make([]Event, 0, w.async.BatchSize)
+	lines := make([][]byte, 0, w.async.BatchSize)
+	var remainder [][]byte
+	var writeErr error
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		// Once a batch failed, the rest of the file is kept as is
+		if writeErr != nil {
+			remainder = append(remainder, slices.Clone(scanner.Bytes()))
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Skip lines that can't be restored (e.g. partially written on crash)
+			appLogger(w.app).Warn("Skipping spilled audit event", "error", err)
+			continue
+		}
+
+		batch = append(batch, event)
+		lines = append(lines, slices.Clone(scanner.Bytes()))
+		if len(batch) >= w.async.BatchSize {
+			if writeErr = w.sinks.writeCollectionFirst(batch); writeErr != nil {
+				remainder = append(remainder, lines...)
+			} else {
+				total += len(batch)
+			}
+			batch = batch[:0]
+			lines = lines[:0]
+		}
+	}
+	if writeErr == nil && len(batch) > 0 {
+		if writeErr = w.sinks.writeCollectionFirst(batch); writeErr != nil {
+			remainder = append(remainder, lines...)
+		} else {
+			total += len(batch)
+		}
+	}
+
+	scanErr := scanner.Err()
+	file.Close()
+
+	switch {
+	case scanErr != nil:
+		appLogger(w.app).Error("Failed to read audit spill file", "path", replayPath, "error", scanErr)
+	case writeErr != nil:
+		appLogger(w.app).Error("Failed to replay spilled audit events, will retry",
+			"path", replayPath, "replayed", total, "remaining", len(remainder), "error", writeErr)
+		if err := rewriteLines(replayPath, remainder); err != nil {
+			appLogger(w.app).Error("Failed to rewrite audit spill file", "path", replayPath, "error", err)
+		}
+	default:
+		if err := os.Remove(replayPath); err != nil {
+			appLogger(w.app).Warn("Failed to remove audit spill file", "path", replayPath, "error", err)
+		}
+	}
+
+	if w.options.LogToConsole && total > 0 {
+		appLogger(w.app).Info("Replayed spilled audit events", "events", total)
+	}
+}
+
+// rewriteLines replaces a JSONL file with the given lines. The lines are
+// written to a temporary file first, so a crash never leaves a partial file.
+func rewriteLines(path string, lines [][]byte) error {
+	var content []byte
+	for _, line := range lines {
+		content = append(append(content, line...), '\n')
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,256 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink collects the IDs of the events written to it. While fail is
+// set every write is rejected.
+type recordingSink struct {
+	mu   sync.Mutex
+	ids  []string
+	fail bool
+}
+
+func (s *recordingSink) Name() string {
+	return "recording"
+}
+
+func (s *recordingSink) Write(events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail {
+		return errors.New("sink unavailable")
+	}
+	for _, event := range events {
+		s.ids = append(s.ids, event.ID)
+	}
+	return nil
+}
+
+func (s *recordingSink) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *recordingSink) written() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.ids)
+}
+
+// newTestWriter creates an async writer (without starting the worker) that
+// writes to a recording sink.
+func newTestWriter(t *testing.T, async AsyncOptions) (*asyncWriter, *recordingSink) {
+	t.Helper()
+
+	options := testOptions()
+	app := newTestApp(t, options)
+
+	if async.SpillPath == "" {
+		async.SpillPath = filepath.Join(t.TempDir(), "spill.jsonl")
+	}
+	if async.BatchSize == 0 {
+		async.BatchSize = 10
+	}
+	if async.FlushInterval == 0 {
+		async.FlushInterval = time.Hour
+	}
+	options.Async = &async
+
+	sink := &recordingSink{}
+	return newAsyncWriter(app, options, newSinkSet(app, options, sink)), sink
+}
+
+// testEvents returns events with the IDs e1, e2, ...
+func testEvents(n int) []Event {
+	events := make([]Event, n)
+	for i := range events {
+		events[i] = Event{ID: fmt.Sprintf("e%d", i+1), EventType: EventTypeCreate}
+	}
+	return events
+}
+
+func eventIDs(events []Event) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func TestAsyncWriterOverflow(t *testing.T) {
+	t.Run("drop", func(t *testing.T) {
+		w, sink := newTestWriter(t, AsyncOptions{QueueSize: 2, Overflow: OverflowDrop})
+
+		events := testEvents(3)
+		for i, event := range events {
+			err := w.enqueue(event)
+			if i < 2 && err != nil {
+				t.Fatalf("event %d: expected to be queued, got %v", i, err)
+			}
+			if i == 2 && !errors.Is(err, errQueueFull) {
+				t.Fatalf("event %d: expected errQueueFull, got %v", i, err)
+			}
+		}
+
+		go w.run()
+		w.stop()
+
+		if ids := sink.written(); !slices.Equal(ids, []string{"e1", "e2"}) {
+			t.Fatalf("expected the queued events only, got %v", ids)
+		}
+	})
+
+	t.Run("spill", func(t *testing.T) {
+		w, sink := newTestWriter(t, AsyncOptions{QueueSize: 2, Overflow: OverflowSpill})
+
+		events := testEvents(4)
+		for i, event := range events {
+			if err := w.enqueue(event); err != nil {
+				t.Fatalf("event %d: %v", i, err)
+			}
+		}
+
+		if _, err := os.Stat(w.async.SpillPath); err != nil {
+			t.Fatalf("expected the overflowing events to be spilled: %v", err)
+		}
+
+		go w.run()
+		w.stop()
+
+		// Spilled events are replayed after the queue was drained
+		if ids := sink.written(); !slices.Equal(ids, []string{"e3", "e4", "e1", "e2"}) {
+			t.Fatalf("expected all events, got %v", ids)
+		}
+		if _, err := os.Stat(w.async.SpillPath); !os.IsNotExist(err) {
+			t.Fatalf("expected the spill file to be removed, got %v", err)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		w, sink := newTestWriter(t, AsyncOptions{QueueSize: 1, Overflow: OverflowBlock})
+
+		events := testEvents(2)
+		if err := w.enqueue(events[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		enqueued := make(chan error)
+		go func() {
+			enqueued <- w.enqueue(events[1])
+		}()
+
+		select {
+		case err := <-enqueued:
+			t.Fatalf("expected enqueue to wait for room in the queue, returned %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		go w.run()
+
+		select {
+		case err := <-enqueued:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("enqueue still blocked after the worker started")
+		}
+
+		w.stop()
+
+		if ids := sink.written(); !slices.Equal(ids, eventIDs(events)) {
+			t.Fatalf("expected all events, got %v", ids)
+		}
+	})
+}
+
+func TestAsyncWriterStopDrainsQueue(t *testing.T) {
+	w, sink := newTestWriter(t, AsyncOptions{QueueSize: 100, BatchSize: 7, Overflow: OverflowBlock})
+	go w.run()
+
+	events := testEvents(20)
+	for _, event := range events {
+		if err := w.enqueue(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// FlushInterval is an hour, so only stop writes the last partial batch
+	w.stop()
+
+	if ids := sink.written(); !slices.Equal(ids, eventIDs(events)) {
+		t.Fatalf("expected all events after stop, got %v", ids)
+	}
+
+	// Events logged after stop are written directly
+	if err := w.enqueue(Event{ID: "late"}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := sink.written(); ids[len(ids)-1] != "late" {
+		t.Fatalf("expected the event logged after stop to be written, got %v", ids)
+	}
+}
+
+func TestReplaySpillKeepsUnwrittenEvents(t *testing.T) {
+	w, sink := newTestWriter(t, AsyncOptions{QueueSize: 1, BatchSize: 2, Overflow: OverflowSpill})
+
+	events := testEvents(5)
+	for _, event := range events {
+		if err := w.spill(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Let the sink fail after the first batch was written
+	failing := &failAfterSink{recordingSink: sink, remaining: 1}
+	w.sinks = newSinkSet(w.app, w.options, failing)
+
+	w.replaySpill()
+
+	if ids := sink.written(); !slices.Equal(ids, []string{"e1", "e2"}) {
+		t.Fatalf("expected the first batch to be written, got %v", ids)
+	}
+
+	replayPath := w.async.SpillPath + ".replay"
+	if _, err := os.Stat(replayPath); err != nil {
+		t.Fatalf("expected the replay file to be kept after a failed write: %v", err)
+	}
+
+	// A later replay writes only the remaining events
+	sink.setFail(false)
+	w.replaySpill()
+
+	if ids := sink.written(); !slices.Equal(ids, eventIDs(events)) {
+		t.Fatalf("expected every event to be written exactly once, got %v", ids)
+	}
+	if _, err := os.Stat(replayPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the replay file to be removed, got %v", err)
+	}
+}
+
+// failAfterSink lets a number of writes through to a recording sink and then
+// makes it fail.
+type failAfterSink struct {
+	*recordingSink
+	remaining int
+}
+
+func (s *failAfterSink) Write(events []Event) error {
+	if s.remaining == 0 {
+		s.setFail(true)
+	}
+	s.remaining--
+
+	return s.recordingSink.Write(events)
+}